@@ -12,8 +12,8 @@
 //
 // The search DSL does not support parsing arbitrary propositions in first
 // order logic, but does allow infinitely nested subqueries, which provides
-// a great deal of flexibility when combined with the three supported
-// modal verbs: Must, Should, MustNot.
+// a great deal of flexibility when combined with the four supported
+// modal verbs: Must, Should, MustNot and Filter.
 //
 // A typical valid search query might look like
 //   "data science" +[math -hype]
@@ -21,8 +21,10 @@
 // the phrase "data science" and must contain the term "math" but not
 // the term "hype".
 //
-// The symbols {, [, ], +, - and , are are reserved and have context-dependent
-// special interpretations.
+// The symbols {, [, ], (, ), +, -, #, :, ^, \ and , are are reserved and have
+// context-dependent special interpretations.  White space, including
+// tabs, line breaks such as CRLF and non-breaking spaces, separates
+// clauses like a space or comma.
 //
 // Phrase Literals
 //
@@ -39,6 +41,15 @@
 //   `c++`
 // results in a parse error, since the + is interpreted as a modal verb.
 //
+// Escape sequences
+//
+// A reverse solidus escapes the rune that follows it, which is then
+// interpreted literally.  Escapes are honored both in bare terms and in
+// phrase literals, so
+//   `c\+\+ "say \"hi\""`
+// searches for the term c++ and the phrase say "hi".  A literal reverse
+// solidus is written as \\.  A query cannot end in a dangling escape.
+//
 // Modal verbs
 //
 // The model verbs "should", "must", and "must not" are supported.  They
@@ -49,14 +60,185 @@
 // is a disjunction over the three terms.
 // These modal verbs can apply words, phrase, and nested queries.
 //
+// The modal verb "filter", represented as "#", requires a clause to match
+// like "must", but its matches do not contribute to relevance, as in the
+// filter context of Elasticsearch.  For example,
+//   `#lang:go generics`
+// restricts the search for generics to documents in the go language.  A
+// literal # must be escaped, as in `c\#`, or quoted.  This is a breaking
+// change: # used to be an ordinary rune, so queries such as c# and a#b,
+// which used to be terms, are now malformed.  Parsers created with
+// WithVerbRune(Filter, 0) read # literally, as before.
+//
 // Subqueries
 //
-// A nested subquery is specified by wrapping it in square brackets.
-// For example,
+// A nested subquery is specified by wrapping it in square brackets
+// or parentheses.  For example,
 //   `"machine learning" +[math data -hype]`
 // specifies a search for documents that should contain the phrase
 // "machine learning", and must contain at least one term from
-// the set {"math", "data"} but not "hype".
+// the set {"math", "data"} but not "hype".  The query
+// `"machine learning" +(math data -hype)` is equivalent.  A subquery
+// must be closed by the bracket matching the one that opened it, so
+// `[math data)` is malformed.
 //
 // Infinite nesting of subqueries is supported.
+//
+// A subquery prefixed with & or ! at the start of a clause, as in
+//   &[golang rust zig] ![hype spam]
+// defaults its clauses to Must or Not respectively, so documents must
+// contain all of golang, rust and zig, and none of hype or spam.  The
+// verb a prefix sets is stored as the Implicit verb of the subquery Node,
+// and WithGroupPrefix changes or disables the prefixes.  Elsewhere, as
+// in R&D or !important, the runes are literal.
+//
+// Wildcards
+//
+// In bare terms, * matches any sequence of runes and ? matches any single
+// rune.  A term such as mach* whose only wildcard is a final * is a prefix
+// term, whose Node has the kind KindPrefix and the phrase mach.  Other
+// terms with wildcards, such as colo?r, have the kind KindWildcard and
+// keep the pattern as their phrase.  Terms cannot begin with a wildcard,
+// as in *ing, unless a parser is created with WithLeadingWildcards.
+// Wildcards are literal in phrases, or when escaped as in `mach\*`.
+//
+// Regular expressions
+//
+// A clause beginning with a slash is a regular expression literal, as in
+//   /go(lang)?/ -title:/v[0-9]+/
+// Like a phrase literal, no symbols are parsed before the closing slash,
+// except that \/ stands for a slash.  The pattern is checked with the
+// regexp/syntax package, and stored as the phrase of a Node of kind
+// KindRegex.  A slash elsewhere in a term, as in and/or, is literal, while
+// a term beginning with a slash must escape it, as in `\/usr`.
+//
+// Fuzzy terms and sloppy phrases
+//
+// A ~ directly following a term or phrase literal begins a suffix rather
+// than a verb.  For a term, as in golang~2, the suffix gives the maximum
+// edit distance of the words it matches, stored as the Fuzziness of the
+// Node.  For a phrase, as in "data science"~3, it gives the maximum number
+// of moves between its words, stored as the Slop of the Node.
+//
+// Boosts
+//
+// A ^ followed by a positive number directly after a term, phrase literal
+// or subquery weights that clause relative to others.  For example, in
+//   golang^3 +[math data]^0.5
+// matches of golang count three times as much as usual towards relevance,
+// and matches of the subquery half as much.  The weight is stored as the
+// Boost of the Node, where zero means the node is not boosted.
+//
+// Minimum should match
+//
+// An @ followed by a positive number directly after a subquery requires
+// at least that many of its Should clauses to match, and a trailing %
+// makes the number a percentage of them.  For example,
+//   [golang rust zig odin]@2 +[a b c d]@75%
+// matches documents containing at least two of the four languages, and at
+// least three of the four letters.  The minimum is stored as the MinShould
+// and Percent of the Node, and Node.GetMinShould resolves percentages.  A
+// minimum cannot exceed the number of Should clauses.  An @ elsewhere,
+// as in ops@corp.io, is literal.
+//
+// Fields
+//
+// A term, phrase or subquery can be scoped to a document field by
+// prefixing it with the field name and a colon, as in
+//   title:golang -author:"Rob Pike" +body:[generics iterators]
+// The field is stored on the qualified node, and Node.GetField reports
+// the field a node inherits from its nearest qualified ancestor.  A
+// colon that is not part of a qualifier must be escaped, as in `12\:30`.
+//
+// Ranges
+//
+// A field qualifier can also be followed by a range of numbers or dates.
+// An interval gives a lower and upper bound separated by TO, between
+// brackets to include a bound or braces to exclude it, where * leaves
+// that side unbounded.  A comparison gives a single bound.  For example,
+//   year:[2010 TO 2020] price:>=10 date:{* TO 2024-01-01}
+// matches years from 2010 to 2020, prices of at least 10 and dates
+// before 2024.  Such a range is a leaf of kind KindRange, whose Range
+// holds the typed bounds.  Brackets only begin an interval when they
+// contain two bounds separated by TO, so title:[go rust] is a subquery.
+// Bounds that are not both numbers or both dates, or that are reversed,
+// are rejected with ErrMalformedRange.
+//
+// Exact matching
+//
+// An = directly before a term or phrase literal at the start of a clause,
+// as in
+//   =Go -author:="Rob Pike"
+// marks it as case- and analyzer-sensitive, so that Go does not match go
+// and no stemming or other normalization applies.  The mark is stored as
+// the Exact flag of the Node, which applies to terms, phrases, prefix and
+// wildcard terms and recognized terms.  The package does not match
+// documents itself, so it is up to the consumer of a tree to honor the
+// flag.  Elsewhere, as in a=b, an = is literal, and a term beginning
+// with one must escape it, as in `\=x`.
+//
+// Existence queries
+//
+// A term qualified by the pseudo field has or _exists_ names a field that
+// must be present.  For example,
+//   has:abstract -_exists_:author
+// matches documents with an abstract but without an author.  Such a
+// query is a leaf of kind KindExists, whose Field is the named field and
+// whose Phrase is empty.  Other clauses qualified by has, such as
+// has:"x y", are ordinary queries of the field has.
+//
+// Dialects
+//
+// The runes described above form the default dialect, which Parse and
+// the other package level functions use.  A Parser created by NewParser
+// with options such as WithVerbRune, WithSeparators, WithGroupDelims and
+// WithPhraseDelims parses a different dialect.  For example,
+//   p := NewParser(WithGroupDelims(Brackets))
+// creates a parser for which parentheses are ordinary runes.  Similarly,
+// WithSmartQuotes(true) accepts the typographic quotation marks found in
+// text pasted from word processors, as in “data science”, as phrase
+// delimiters, each closed by its matching rune.  WithClauseStartVerbs(true)
+// only treats verb runes as verbs at the start of a clause, so that
+// hyphenated words, ISO dates and terms such as c++ are single terms,
+// while `x -y` still excludes y.  WithRecognizers(true) recognizes URLs,
+// email addresses and version-like identifiers, such as
+// https://example.com/a-b?x=1, ops+alerts@corp.io and foo-bar_v2.3, as
+// single leaves of kind KindURL, KindEmail and KindIdentifier, despite
+// the reserved runes they contain.
+//
+// The implicit verb of unmarked clauses is set with WithDefaultVerb, and
+// that of clauses inside subqueries with WithSubqueryDefaultVerb.  With
+//   p := NewParser(WithDefaultVerb(Must))
+// the query `x y z` is a conjunction instead of a disjunction.  The
+// Parser.Format method converts a tree back into a query in the parser's
+// dialect, omitting implicit verbs.
+//
+// Keyword operators
+//
+// A dialect created with WithKeywords also recognizes the keyword
+// operators AND, OR and NOT outside of phrase literals.  These are
+// lowered into verbs and subqueries, so that
+//   golang AND [generics OR iterators] NOT java
+// parses to the same tree as `+golang +[generics iterators] -java`.
+//
+// The proximity operators NEAR/k and ONEAR/k join the clauses on either
+// side into a single near clause, which matches when they occur within k
+// words of each other, and in order for ONEAR.  For example,
+//   "breach" NEAR/5 "contract"
+// yields a node of kind KindNear with a Slop of 5, whose two operands
+// must both match.
+//
+// Lenient parsing
+//
+// Queries typed into a search box are frequently malformed.  ParseLenient
+// repairs such queries instead of failing, for instance by treating the
+// verbs in `c++` as literal text or closing unclosed subqueries, and
+// reports each repair as a Diagnostic.
+//
+// Tokens
+//
+// Parse consumes the stream of tokens produced by a Lexer.  Each Token
+// records its kind and its span in the query, which is useful for tools
+// such as syntax highlighters that need the query's lexical structure
+// rather than its parsed tree.
 package gossip
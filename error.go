@@ -1,12 +1,146 @@
 package gossip
 
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
 // Define some common error codes.
 const (
 	ErrorMalformedQuery         = "gossip: Search query is malformed. "
 	ErrorUnpairedQuotation      = ErrorMalformedQuery + "Unpaired quotation mark."
 	ErrorUnpairedBracket        = ErrorMalformedQuery + "Unpaired bracket."
+	ErrorMismatchedBracket      = ErrorMalformedQuery + "Mismatched bracket."
 	ErrorUnexpectedReservedRune = ErrorMalformedQuery + "Unexpected reserved rune."
 	ErrorEmptyQuery             = ErrorMalformedQuery + "Semantically empty."
 	ErrorVerbSequence           = ErrorMalformedQuery + "Unexpected verb sequence."
+	ErrorDanglingEscape         = ErrorMalformedQuery + "Dangling escape."
+	ErrorOperatorSequence       = ErrorMalformedQuery + "Unexpected operator sequence."
+	ErrorLeadingWildcard        = ErrorMalformedQuery + "Leading wildcard."
+	ErrorMalformedModifier      = ErrorMalformedQuery + "Malformed modifier."
+	ErrorMalformedRange         = ErrorMalformedQuery + "Malformed range."
+	ErrorMalformedRegex         = ErrorMalformedQuery + "Malformed regular expression."
 	ErrorVerbString             = "gossip: Verb string is not recognized."
 )
+
+// Sentinel errors corresponding to the error codes.  The errors returned
+// by Parse wrap one of these, so they can be tested with errors.Is.
+var (
+	ErrMalformedQuery         = errors.New(strings.TrimSpace(ErrorMalformedQuery))
+	ErrUnpairedQuotation      = errors.New(ErrorUnpairedQuotation)
+	ErrUnpairedBracket        = errors.New(ErrorUnpairedBracket)
+	ErrMismatchedBracket      = errors.New(ErrorMismatchedBracket)
+	ErrUnexpectedReservedRune = errors.New(ErrorUnexpectedReservedRune)
+	ErrEmptyQuery             = errors.New(ErrorEmptyQuery)
+	ErrVerbSequence           = errors.New(ErrorVerbSequence)
+	ErrDanglingEscape         = errors.New(ErrorDanglingEscape)
+	ErrOperatorSequence       = errors.New(ErrorOperatorSequence)
+	ErrLeadingWildcard        = errors.New(ErrorLeadingWildcard)
+	ErrMalformedModifier      = errors.New(ErrorMalformedModifier)
+	ErrMalformedRange         = errors.New(ErrorMalformedRange)
+	ErrMalformedRegex         = errors.New(ErrorMalformedRegex)
+	ErrVerbString             = errors.New(ErrorVerbString)
+)
+
+// ParseError describes where and why a search query failed to parse.
+// Every ParseError is a malformed query, so errors.Is reports true for
+// ErrMalformedQuery in addition to the wrapped sentinel.
+type ParseError struct {
+	Query      string   // Query being parsed.
+	Offset     int      // Byte offset of the offending rune.
+	RuneOffset int      // Rune offset of the offending rune.
+	Line       int      // Line of the offending rune, starting at 1.
+	Column     int      // Column of the offending rune in runes, starting at 1.
+	Rune       rune     // Offending rune, or utf8.RuneError at the end of input.
+	Expected   []string // Alternatives that would have been accepted, if known.
+	Err        error    // Sentinel error describing the problem.
+}
+
+// newParseError creates an error for the rune at byte offset i of s.
+func newParseError(s string, i int, err error, expected ...string) *ParseError {
+	if i < 0 {
+		i = 0
+	}
+	if i > len(s) {
+		i = len(s)
+	}
+
+	e := &ParseError{
+		Query:      s,
+		Offset:     i,
+		RuneOffset: utf8.RuneCountInString(s[:i]),
+		Line:       strings.Count(s[:i], "\n") + 1,
+		Rune:       utf8.RuneError,
+		Expected:   expected,
+		Err:        err,
+	}
+
+	lineStart := strings.LastIndex(s[:i], "\n") + 1
+	e.Column = utf8.RuneCountInString(s[lineStart:i]) + 1
+
+	if i < len(s) {
+		e.Rune, _ = utf8.DecodeRuneInString(s[i:])
+	}
+	return e
+}
+
+func (e *ParseError) Error() string {
+	msg := fmt.Sprintf("%s Line %d, column %d.", e.Err, e.Line, e.Column)
+	if len(e.Expected) > 0 {
+		msg += fmt.Sprintf(" Expected %s.", strings.Join(e.Expected, " or "))
+	}
+	return msg
+}
+
+// Unwrap returns the sentinel error.
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the target is ErrMalformedQuery.  Other sentinels
+// are matched through Unwrap.
+func (e *ParseError) Is(target error) bool {
+	return target == ErrMalformedQuery
+}
+
+// Repair identifies how ParseLenient recovered from a problem.
+type Repair int
+
+// Repairs applied by ParseLenient.
+const (
+	RepairNone      Repair = iota // The problem could not be repaired.
+	RepairLiteral                 // Reserved runes were treated as literal text.
+	RepairDropped                 // Runes or empty clauses were dropped.
+	RepairClosed                  // An unclosed subquery was closed at the end of the query.
+	RepairSeparated               // A missing separator was assumed.
+	RepairMatched                 // A mismatched bracket closed the open subquery.
+)
+
+var repairStrings = map[Repair]string{
+	RepairNone:      "not repaired",
+	RepairLiteral:   "treated as literal text",
+	RepairDropped:   "dropped",
+	RepairClosed:    "closed at end of query",
+	RepairSeparated: "separator assumed",
+	RepairMatched:   "treated as matching bracket",
+}
+
+func (r Repair) String() string {
+	if rs, ok := repairStrings[r]; ok {
+		return rs
+	}
+	return "_error"
+}
+
+// Diagnostic describes a problem found by ParseLenient and how it was
+// repaired.
+type Diagnostic struct {
+	*ParseError
+	Repair Repair
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s Repair: %s.", d.ParseError, d.Repair)
+}
@@ -0,0 +1,77 @@
+package gossip
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewParseError(t *testing.T) {
+	tests := []struct {
+		s          string
+		i          int
+		runeOffset int
+		line       int
+		column     int
+		r          rune
+	}{
+		{"", 0, 0, 1, 1, utf8.RuneError},
+		{"abc", 1, 1, 1, 2, 'b'},
+		{"abc", 3, 3, 1, 4, utf8.RuneError},
+		{"abc", 9, 3, 1, 4, utf8.RuneError},
+		{"abc", -1, 0, 1, 1, 'a'},
+		{"日本語", 6, 2, 1, 3, '語'},
+		{"ab\ncd", 4, 4, 2, 2, 'd'},
+		{"ab\r\n日本", 7, 5, 2, 2, '本'},
+	}
+
+	for i, tt := range tests {
+		msg := fmt.Sprintf("Fails test case (%d) %#v", i, tt)
+		e := newParseError(tt.s, tt.i, ErrMalformedQuery)
+		assert.Equal(t, tt.runeOffset, e.RuneOffset, msg)
+		assert.Equal(t, tt.line, e.Line, msg)
+		assert.Equal(t, tt.column, e.Column, msg)
+		assert.Equal(t, tt.r, e.Rune, msg)
+	}
+}
+
+func TestParseErrorIs(t *testing.T) {
+	var err error = newParseError(`"x`, 0, ErrUnpairedQuotation)
+	assert.True(t, errors.Is(err, ErrUnpairedQuotation))
+	assert.True(t, errors.Is(err, ErrMalformedQuery))
+	assert.False(t, errors.Is(err, ErrUnpairedBracket))
+
+	var perr *ParseError
+	assert.True(t, errors.As(err, &perr))
+	assert.Equal(t, ErrUnpairedQuotation, perr.Unwrap())
+}
+
+func TestParseErrorMessage(t *testing.T) {
+	e := newParseError("a +", 2, ErrVerbSequence, "term", "phrase")
+	assert.Equal(
+		t,
+		ErrorVerbSequence+" Line 1, column 3. Expected term or phrase.",
+		e.Error(),
+	)
+
+	e = newParseError("a", 1, ErrEmptyQuery)
+	assert.Equal(t, ErrorEmptyQuery+" Line 1, column 2.", e.Error())
+}
+
+func TestRepairString(t *testing.T) {
+	assert.Equal(t, "dropped", RepairDropped.String())
+	assert.Equal(t, "closed at end of query", RepairClosed.String())
+	assert.Equal(t, "treated as matching bracket", RepairMatched.String())
+	assert.Equal(t, "_error", Repair(-1).String())
+}
+
+func TestDiagnosticString(t *testing.T) {
+	d := Diagnostic{
+		ParseError: newParseError("a +", 2, ErrVerbSequence),
+		Repair:     RepairDropped,
+	}
+	assert.Equal(t, ErrorVerbSequence+" Line 1, column 3. Repair: dropped.", d.String())
+}
@@ -0,0 +1,451 @@
+package gossip
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// formatter converts query trees into query strings.  Canonical
+// formatters produce the output of Node.String, in which every verb is
+// explicit, every phrase is quoted and every subquery is bracketed.
+// Otherwise, the output is a query in the parser's dialect that parses
+// to the same tree.
+type formatter struct {
+	p         *Parser
+	canonical bool
+}
+
+// Format converts the tree rooted at the node into a query in the
+// parser's dialect.  Verbs equal to the implicit verb of their clause
+// are omitted, terms are only quoted when they contain reserved runes,
+// and the clauses of the root are not bracketed.  For example,
+// a parser with a default verb of Must formats the tree of
+// `+x +"data science" -y` as `x "data science" -y`.  Invalid trees are
+// formatted as the empty string.
+func (p *Parser) Format(n *Node) string {
+	return formatter{p: p}.format(n)
+}
+
+func (f formatter) format(n *Node) string {
+	if n == nil {
+		return ""
+	}
+
+	// Parse produces roots with the verb Should, whose children are the
+	// top level clauses of the query.
+	if !f.canonical && !n.IsLeaf() && n.Kind == KindText && n.Verb == Should && n.Field == "" && n.Boost == 0 &&
+		n.MinShould == 0 && n.Implicit == 0 {
+		return f.clauses(n, 0)
+	}
+	return f.node(n, 0)
+}
+
+// node formats a node at the input subquery depth.
+func (f formatter) node(n *Node, depth int) string {
+	body := f.body(n, depth)
+	verb, ok := f.verb(n.Verb, f.implicit(n, depth))
+	if body == "" || !ok {
+		return ""
+	}
+	return verb + body
+}
+
+// implicit returns the verb of unmarked clauses at the input subquery
+// depth, which the group prefix of the node's parent can set.
+func (f formatter) implicit(n *Node, depth int) Verb {
+	if parent := n.GetParent(); depth > 0 && parent != nil && parent.Implicit != 0 {
+		return parent.Implicit
+	}
+	return f.p.implicitVerb(depth)
+}
+
+// body formats a node at the input subquery depth without its verb.
+func (f formatter) body(n *Node, depth int) string {
+	field, ok := f.field(n.Field)
+	if n.Kind == KindExists {
+		// The field of an existence query is formatted as its term.
+		field, ok = "", true
+	}
+	if !ok {
+		return ""
+	}
+	boost, ok := f.boost(n.Boost)
+	if !ok {
+		return ""
+	}
+
+	// Just return the phrase if the root is a leaf.  A term directly
+	// following the field that would read as a range is escaped.
+	if n.IsLeaf() {
+		exact, ok := f.exact(n.Exact)
+		phrase := f.leaf(n)
+		if field != "" && exact == "" && n.Kind != KindRange && f.ranged(phrase) {
+			phrase = string(Escape) + phrase
+		}
+		if n.IsValid() && phrase != "" && ok {
+			return field + exact + phrase + boost
+		}
+		return ""
+	}
+
+	// The operands of a near clause are at its depth, and their verbs
+	// are omitted since both must match.
+	if n.Kind == KindNear {
+		if !n.IsValid() || field != "" || boost != "" {
+			return ""
+		}
+		return f.near(n, depth)
+	}
+
+	// Otherwise convert each child to a string.  The result might
+	// look something like [+w0 +"phrase1" -[...]]
+	clauses := f.clauses(n, depth+1)
+	min, ok := f.minShould(n)
+	if !ok {
+		return ""
+	}
+	prefix, ok := f.groupPrefix(n.Implicit)
+	if clauses == "" || len(f.p.groups) == 0 || !ok {
+		return ""
+	}
+	group := f.p.groups[0]
+	return field + prefix + string(group.Start) + clauses + string(group.End) + min + boost
+}
+
+// near formats the operands of a near clause joined by its proximity
+// keyword.  Canonical formatters use the spelling of DefaultKeywords, and
+// bracket the operands after the keyword, as in NEAR/5["a", "b"], which no
+// dialect without keywords reads as terms.  It returns the empty string if
+// the parser's dialect cannot express the clause.
+func (f formatter) near(n *Node, depth int) string {
+	k := DefaultKeywords
+	if !f.canonical {
+		if f.p.keywords == nil {
+			return ""
+		}
+		k = *f.p.keywords
+	}
+	keyword := k.Near
+	if n.Ordered {
+		keyword = k.ONear
+	}
+	// Proximity operators associate to the left, so a right operand that
+	// is itself a near clause cannot be expressed.
+	if keyword == "" || !f.canonical && n.Children[1].Kind == KindNear {
+		return ""
+	}
+
+	left, right := f.body(n.Children[0], depth), f.body(n.Children[1], depth)
+	if left == "" || right == "" {
+		return ""
+	}
+	keyword += string(NearSlop) + strconv.Itoa(n.Slop)
+	if f.canonical {
+		group := f.p.groups[0]
+		return keyword + string(group.Start) + left + f.separator() + right + string(group.End)
+	}
+	return left + " " + keyword + " " + right
+}
+
+// groupPrefix formats the group prefix setting the implicit verb of a
+// subquery.  It reports false if the parser's dialect has no prefix for
+// the verb.
+func (f formatter) groupPrefix(v Verb) (string, bool) {
+	if v == 0 {
+		return "", true
+	}
+	for r, vi := range f.p.prefixes {
+		if vi == v {
+			return string(r), true
+		}
+	}
+	return "", false
+}
+
+// exact formats the exact-match prefix of a term or phrase.  It reports
+// false if the parser's dialect cannot express exact matching.
+func (f formatter) exact(ok bool) (string, bool) {
+	switch {
+	case !ok:
+		return "", true
+	case f.canonical:
+		return string(EqualsSign), true
+	case f.p.exact == 0:
+		return "", false
+	}
+	return string(f.p.exact), true
+}
+
+// minShould formats the minimum should match suffix of a subquery.  It
+// reports false if the parser's dialect cannot express the minimum.
+func (f formatter) minShould(n *Node) (string, bool) {
+	switch {
+	case n.MinShould == 0:
+		return "", true
+	case f.p.minShould == 0:
+		return "", false
+	}
+	min := f.suffix(f.p.minShould, n.MinShould)
+	if n.Percent {
+		min += "%"
+	}
+	return min, true
+}
+
+// boost formats a boost suffix.  It reports false if the parser's
+// dialect cannot express the boost.
+func (f formatter) boost(b float64) (string, bool) {
+	switch {
+	case b == 0:
+		return "", true
+	case f.p.boost == 0:
+		return "", false
+	}
+	return string(f.p.boost) + strconv.FormatFloat(b, 'f', -1, 64), true
+}
+
+// field formats a field qualifier.  It reports false if the parser's
+// dialect cannot qualify clauses by the field.
+func (f formatter) field(field string) (string, bool) {
+	switch {
+	case field == "":
+		return "", true
+	case f.p.field == 0:
+		return "", false
+	}
+	name := escape(field, f.p.IsReserved)
+	if f.specialStart(field) {
+		name = string(Escape) + name
+	}
+	return name + string(f.p.field), true
+}
+
+// clauses formats the children of a node, which are at the input
+// subquery depth.
+func (f formatter) clauses(n *Node, depth int) string {
+	strs := make([]string, len(n.Children))
+	for i, child := range n.Children {
+		substring := f.node(child, depth)
+		if substring == "" {
+			return ""
+		}
+		strs[i] = substring
+	}
+	return strings.Join(strs, f.separator())
+}
+
+// verb formats the verb of a clause whose implicit verb is the input.
+// It reports false if the parser's dialect has no rune for the verb.
+func (f formatter) verb(v Verb, implicit Verb) (string, bool) {
+	switch {
+	case f.canonical:
+		return v.String(), true
+	case v == implicit:
+		return "", true
+	}
+	s := f.p.verbString(v)
+	return s, s != VerbErrorString
+}
+
+// leaf formats the phrase of a leaf according to its kind.  It returns
+// the empty string if the parser's dialect cannot express the leaf.
+func (f formatter) leaf(n *Node) string {
+	switch n.Kind {
+	case KindPrefix:
+		if !f.p.wildcards {
+			return ""
+		}
+		return f.term(n.Phrase) + string(Asterisk)
+
+	case KindWildcard:
+		r, _ := utf8.DecodeRuneInString(n.Phrase)
+		if !f.p.wildcards || !f.canonical && !f.p.leading && f.p.isWildcard(r) {
+			return ""
+		}
+		return f.pattern(n.Phrase)
+
+	case KindRange:
+		return n.Range.String()
+
+	case KindExists:
+		return f.exists(n.Field)
+
+	case KindURL, KindEmail, KindIdentifier:
+		if !f.canonical && !f.p.recognize || strings.IndexFunc(n.Phrase, f.p.endsRecognized) != -1 {
+			return ""
+		}
+		return n.Phrase
+
+	case KindRegex:
+		if f.p.regex == 0 {
+			return ""
+		}
+		return f.regex(n.Phrase)
+	}
+
+	switch {
+	case n.Fuzziness == 0 && n.Slop == 0 && !f.canonical && f.p.isExists(n.Field),
+		n.Fuzziness == 0 && n.Slop == 0 && n.Field != "" && !n.Exact && f.ranged(n.Phrase):
+		// A bare term would be an existence query or a range.
+		if len(f.p.phrases) == 0 {
+			return ""
+		}
+		return f.quote(n.Phrase, f.p.phrases[0])
+	case n.Fuzziness == 0 && n.Slop == 0:
+		return f.phrase(n.Phrase)
+	case f.p.fuzzy == 0:
+		return ""
+	case n.Fuzziness > 0:
+		return f.term(n.Phrase) + f.suffix(f.p.fuzzy, n.Fuzziness)
+	case len(f.p.phrases) == 0:
+		return ""
+	}
+
+	// Phrases with a slop are always quoted, to tell them from terms.
+	d := f.p.phrases[0]
+	return f.quote(n.Phrase, d) + f.suffix(f.p.fuzzy, n.Slop)
+}
+
+// exists formats an existence query of the field.  Canonical formatters
+// use the first of ExistsFields.  It returns the empty string if the
+// parser's dialect cannot express the query.
+func (f formatter) exists(field string) string {
+	names := f.p.exists
+	if f.canonical {
+		names = ExistsFields
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	name, ok := f.field(names[0])
+	if !ok {
+		return ""
+	}
+	return name + f.term(field)
+}
+
+// suffix formats a suffix with an integer argument.
+func (f formatter) suffix(r rune, n int) string {
+	return string(r) + strconv.Itoa(n)
+}
+
+// pattern formats a wildcard pattern, whose escape sequences are kept.
+func (f formatter) pattern(pattern string) string {
+	var (
+		b       strings.Builder
+		escaped bool
+	)
+	if f.specialStart(pattern) {
+		b.WriteRune(Escape)
+	}
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			escaped = false
+		case IsEscape(r):
+			escaped = true
+		case f.p.IsReserved(r):
+			b.WriteRune(Escape)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// term formats a bare term, escaping the runes that would otherwise be
+// special, including a leading regular expression delimiter or
+// exact-match prefix, and the first rune of a keyword.
+func (f formatter) term(s string) string {
+	t := escape(s, f.special)
+	if f.specialStart(s) || f.p.operator(t) != opNone {
+		t = string(Escape) + t
+	}
+	return t
+}
+
+// specialStart states if the input begins with a regular expression
+// delimiter or exact-match prefix, which must be escaped at the start of
+// a bare term.
+func (f formatter) specialStart(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return f.p.regex != 0 && r == f.p.regex || f.p.isExact(r)
+}
+
+// ranged states if the input could begin a range directly following a
+// field qualifier.  A brace begins one if the rest of the query closes it.
+func (f formatter) ranged(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return r == '<' || r == '>' || r == RangeExclusiveStart
+}
+
+// regex formats a regular expression literal.  Delimiters in the
+// pattern are escaped, unless they already are.
+func (f formatter) regex(pattern string) string {
+	var (
+		b       strings.Builder
+		d       = f.p.regex
+		escaped bool
+	)
+	b.WriteRune(d)
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			escaped = false
+		case IsEscape(r):
+			escaped = true
+		case r == d:
+			b.WriteRune(Escape)
+		}
+		b.WriteRune(r)
+	}
+	b.WriteRune(d)
+	return b.String()
+}
+
+// special states if the input must be escaped or quoted in a bare term.
+func (f formatter) special(r rune) bool {
+	return f.p.IsReserved(r) || f.p.isWildcard(r)
+}
+
+// phrase formats the phrase of a leaf, quoting it only when required,
+// such as when it contains reserved runes, is spelled like a keyword or
+// would be recognized as a URL, email address or identifier.
+func (f formatter) phrase(phrase string) string {
+	if f.canonical {
+		return string(Quote) + escapePhrase(phrase) + string(Quote)
+	}
+
+	bare := strings.IndexFunc(phrase, f.special) == -1 && f.p.operator(phrase) == opNone &&
+		!(f.p.recognize && recognize(phrase) != KindText)
+	if bare || len(f.p.phrases) == 0 {
+		return f.term(phrase)
+	}
+
+	return f.quote(phrase, f.p.phrases[0])
+}
+
+// quote formats a phrase literal between the delimiters.
+func (f formatter) quote(phrase string, d Delims) string {
+	return string(d.Start) + escape(phrase, func(r rune) bool {
+		return r == d.Start || r == d.End || IsEscape(r)
+	}) + string(d.End)
+}
+
+// separator returns the string separating clauses.
+func (f formatter) separator() string {
+	if f.canonical {
+		return ", "
+	}
+	if _, ok := f.p.separators[Space]; ok || len(f.p.separators) == 0 {
+		return string(Space)
+	}
+
+	seps := make([]int, 0, len(f.p.separators))
+	for r := range f.p.separators {
+		seps = append(seps, int(r))
+	}
+	sort.Ints(seps)
+	return string(rune(seps[0]))
+}
@@ -0,0 +1,128 @@
+package gossip
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParserFormat(t *testing.T) {
+	and := NewParser(WithDefaultVerb(Must))
+	mixed := NewParser(WithDefaultVerb(Must), WithSubqueryDefaultVerb(Should))
+	keywords := NewParser(WithKeywords(DefaultKeywords))
+	recognizers := NewParser(WithRecognizers(true))
+	parens := NewParser(
+		WithGroupDelims(Delims{'(', ')'}),
+		WithPhraseDelims(Delims{'«', '»'}),
+		WithSeparators(';'),
+	)
+
+	tests := []struct {
+		p   *Parser
+		in  string
+		out string
+	}{
+		{defaultParser, "w", "w"},
+		{defaultParser, "+w", "+w"},
+		{defaultParser, `x +"data science" -[y ~z]`, `x +"data science" -[y z]`},
+		{defaultParser, `c\+\+ "say \"hi\""`, `"c++" "say \"hi\""`},
+		{defaultParser, `[[x] y]`, `[[x] y]`},
+		{and, "x +y ~z", "x y ~z"},
+		{and, "#x [#y z]", "#x [#y z]"},
+		{and, "x [y ~z]", "x [y ~z]"},
+		{and, "x ~[y ~z]", "x ~[y ~z]"},
+		{mixed, "x [y +z]", "x [y +z]"},
+		{mixed, "~x +[y z]", "~x [y z]"},
+		{parens, `+x;(y;"z")`, `+x;(y;"z")`},
+		{parens, `x;«y z»`, `x;«y z»`},
+		{parens, `«a;b»`, `«a;b»`},
+		{parens, `«a\»b»`, `«a\»b»`},
+		{defaultParser, `-title:"data science" +t:[a b:c]`, `-title:"data science" +t:[a b:c]`},
+		{defaultParser, `a\:b:c`, `a\:b:c`},
+		{defaultParser, `t:[a]`, `t:[a]`},
+		{defaultParser, `mach* -t:colo?r`, `mach* -t:colo?r`},
+		{defaultParser, `"a*b" a\*\?`, `"a*b" "a*?"`},
+		{defaultParser, `a\[\*?`, `a\[\*?`},
+		{parens, `x;a\*`, `x;«a*»`},
+		{defaultParser, `go~1 +"data science"~3`, `go~1 +"data science"~3`},
+		{defaultParser, `"go"~2 t:c\+\+~1`, `"go"~2 t:c\+\+~1`},
+		{defaultParser, `golang^3 +[math data]^0.5`, `golang^3 +[math data]^0.5`},
+		{defaultParser, `"a b"~1^2 mach*^1.5 x\^2`, `"a b"~1^2 mach*^1.5 "x^2"`},
+		{defaultParser, `year:[2010 TO 2020] -date:{2024-01-01 TO *]^2`, `year:[2010 TO 2020] -date:>2024-01-01^2`},
+		{parens, `price:<=10;x`, `price:<=10;x`},
+		{defaultParser, `x:"<5" x:\>5 x:\<b\> title:\{draft} title:\{a TO b}`, `x:"<5" x:">5" x:"<b>" title:"{draft}" title:"{a" TO b}`},
+		{defaultParser, `x:\<a* x:\>a~1 x:=">5" title:\{a?}`, `x:\<a* x:\>a~1 x:=>5 title:\{a?}`},
+		{defaultParser, `/go(lang)?/ +t:/a\/b/^2 and/or`, `/go(lang)?/ +t:/a\/b/^2 and/or`},
+		{defaultParser, `\/x \/y* \/a?b \/c~1 \/t:z`, `\/x \/y* \/a?b \/c~1 \/t:z`},
+		{defaultParser, `[a b c]@2 -t:(a b)@50%^2`, `[a b c]@2 -t:[a b]@50%^2`},
+		{keywords, `"breach" NEAR/5 "contract"`, `breach NEAR/5 contract`},
+		{defaultParser, `&[a -b] x ![c [d]]^2`, `&[a -b] x ![c [d]]^2`},
+		{defaultParser, `=Go -title:="Rob Pike" =\=x \=y =go*`, `=Go -title:="Rob Pike" =\=x \=y =go*`},
+		{and, `x &[a ~b] ![c]`, `x &[a ~b] ![c]`},
+		{defaultParser, `has:abstract -_exists_:a\:b^2 has:"x" has:[x]`, `has:abstract -has:a\:b^2 has:"x" has:[x]`},
+		{keywords, `x -t:a ONEAR/0 [b c]^2 NEAR/3 d~1`, `x -t:a ONEAR/0 [b c]^2 NEAR/3 d~1`},
+		{keywords, `"OR"`, `"OR"`},
+		{keywords, `a "AND" b`, `a "AND" b`},
+		{keywords, `\NEAR/2 title:NOT`, `"NEAR/2" title:"NOT"`},
+		{keywords, `\AND~1 =OR`, `\AND~1 ="OR"`},
+		{recognizers, `v1.2 \v1.2 t:\hasv1.2 a@b.io\v1.2`, `v1.2 "v1.2" t:"hasv1.2" "a@b.iov1.2"`},
+	}
+
+	for i, tt := range tests {
+		msg := fmt.Sprintf("Fails test case (%d) %s", i, tt.in)
+		tree, err := tt.p.Parse(tt.in)
+		assert.NoError(t, err, msg)
+		out := tt.p.Format(tree)
+		assert.Equal(t, tt.out, out, msg)
+
+		// Formatted queries parse to the same tree.
+		again, err := tt.p.Parse(out)
+		assert.NoError(t, err, msg)
+		assert.True(t, tree.Tree().Equals(again.Tree()), msg)
+	}
+}
+
+func TestParserFormatInvalid(t *testing.T) {
+	h1 := NewNode()
+	h1.NewChild()
+
+	assert.Equal(t, "", defaultParser.Format(nil))
+	assert.Equal(t, "", defaultParser.Format(NewNode()))
+	assert.Equal(t, "", defaultParser.Format(h1))
+
+	// Fields cannot be expressed without a field delimiter.
+	p := NewParser(WithFieldDelim(0))
+	assert.Equal(t, "", p.Format(NewNode().SetPhrase("x").SetField("title")))
+
+	// Wildcards cannot be expressed if they are disabled, and leading
+	// wildcards if they are not allowed.
+	p = NewParser(WithWildcards(false))
+	assert.Equal(t, "", p.Format(NewNode().SetPhrase("mach").SetKind(KindPrefix)))
+	assert.Equal(t, "", defaultParser.Format(NewNode().SetPhrase("*ing").SetKind(KindWildcard)))
+
+	// Group prefixes cannot be expressed if they are disabled.
+	p = NewParser(WithGroupPrefix(Must, 0))
+	tree, err := Parse("&[a b]")
+	assert.NoError(t, err)
+	assert.Equal(t, "", p.Format(tree))
+
+	// Nor can verbs without a rune.
+	p = NewParser(WithVerbRune(Filter, 0))
+	tree, err = Parse("#x y")
+	assert.NoError(t, err)
+	assert.Equal(t, "", p.Format(tree))
+
+	// Nor can exact matching.
+	p = NewParser(WithExactRune(0))
+	tree, err = Parse("=Go")
+	assert.NoError(t, err)
+	assert.Equal(t, "", p.Format(tree))
+
+	// Near clauses cannot be expressed without the proximity keywords.
+	near := NewNode().AddChild(&Node{Verb: Must, Phrase: "x"}).AddChild(&Node{Verb: Must, Phrase: "y"}).SetNear(1, true)
+	assert.Equal(t, "", defaultParser.Format(near))
+	p = NewParser(WithKeywords(Keywords{Near: "NEAR"}))
+	assert.Equal(t, "", p.Format(near))
+	assert.Equal(t, "x NEAR/1 y", p.Format(near.SetNear(1, false)))
+}
@@ -2,6 +2,7 @@ package gossip
 
 import (
 	"encoding/json"
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -24,6 +25,9 @@ func TestUnmarshalJSONBad(t *testing.T) {
 	n, err = UnmarshalJSON([]byte("garbage"))
 	assert.Error(t, err)
 	assert.Nil(t, n)
+
+	_, err = UnmarshalJSON([]byte(`{"verb":1,"kind":3,"field":"y","range":{"type":1,"lower":{"text":"x"}}}`))
+	assert.Error(t, err)
 }
 
 func TestUnmarshalJSON(t *testing.T) {
@@ -32,4 +36,38 @@ func TestUnmarshalJSON(t *testing.T) {
 	assert.NoError(t, err)
 	n1, err := UnmarshalJSON(data)
 	assert.Equal(t, n0, n1)
+
+	keywords := NewParser(WithKeywords(DefaultKeywords))
+	tests := []struct {
+		p   *Parser
+		in  string
+		out []string // Fragments of the JSON encoding.
+	}{
+		{defaultParser, "x +[y z]", []string{`"verb":43,"start":2,"end":8`}},
+		{defaultParser, `author:"Rob Pike" title:[go rust]`, []string{`"field":"title"`}},
+		{defaultParser, "mach* colo?r", []string{`"kind":1,"phrase":"mach"`, `"kind":2,"phrase":"colo?r"`}},
+		{defaultParser, `golang~2 "data science"~3`, []string{`"fuzziness":2`, `"slop":3`}},
+		{defaultParser, "golang^3 +[math data]^0.5", []string{`"boost":3`, `"boost":0.5`}},
+		{defaultParser, "year:[2010 TO 2020] date:<2024-01-01", []string{`"range":{"type":1,"lower":{"text":"2010","inclusive":true}`}},
+		{defaultParser, "[a b c]@2 (a b)@50%", []string{`"min_should":2`, `"min_should":50,"percent":true`}},
+		{keywords, `"breach" ONEAR/5 "contract" x`, []string{`"kind":5,"slop":5,"ordered":true`}},
+		{defaultParser, "has:abstract -has:author", []string{`"verb":45,"kind":6,"field":"author"`}},
+		{defaultParser, "&[a b] ![c]", []string{`"implicit":43`, `"implicit":45`}},
+		{defaultParser, `=Go -title:="Rob Pike"`, []string{`"verb":126,"exact":true,"phrase":"Go"`}},
+		{defaultParser, "#x y", []string{`"verb":35`}},
+	}
+
+	for i, tt := range tests {
+		msg := fmt.Sprintf("Fails test case (%d) %s", i, tt.in)
+		n0, err := tt.p.Parse(tt.in)
+		assert.NoError(t, err, msg)
+		data, err := json.Marshal(n0)
+		assert.NoError(t, err, msg)
+		for _, out := range tt.out {
+			assert.Contains(t, string(data), out, msg)
+		}
+		n1, err := UnmarshalJSON(data)
+		assert.NoError(t, err, msg)
+		assert.Equal(t, n0, n1, msg)
+	}
 }
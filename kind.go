@@ -0,0 +1,53 @@
+package gossip
+
+// NodeKind identifies how a node is matched against documents.
+type NodeKind int
+
+// Node kinds.  The zero kind is a plain term, phrase literal or subquery.
+const (
+	KindText       NodeKind = iota // Term, phrase or subquery matched as text.
+	KindPrefix                     // Term matching words with its phrase as a prefix, as in mach*.
+	KindWildcard                   // Term whose phrase is a wildcard pattern, as in colo?r.
+	KindRange                      // Range of numbers or dates, as in year:[2010 TO 2020].
+	KindRegex                      // Term whose phrase is a regular expression, as in /go(lang)?/.
+	KindNear                       // Two clauses within a number of words, as in breach NEAR/5 contract.
+	KindExists                     // Field that must be present, as in has:abstract.
+	KindURL                        // Term recognized as a URL, as in https://example.com/a-b.
+	KindEmail                      // Term recognized as an email address, as in ops+alerts@corp.io.
+	KindIdentifier                 // Term recognized as a version-like identifier, as in foo-bar_v2.3.
+)
+
+var nodeKindStrings = map[NodeKind]string{
+	KindText:       "text",
+	KindPrefix:     "prefix",
+	KindWildcard:   "wildcard",
+	KindRange:      "range",
+	KindRegex:      "regex",
+	KindNear:       "near",
+	KindExists:     "exists",
+	KindURL:        "url",
+	KindEmail:      "email",
+	KindIdentifier: "identifier",
+}
+
+func (k NodeKind) String() string {
+	if ks, ok := nodeKindStrings[k]; ok {
+		return ks
+	}
+	return "_error"
+}
+
+// IsValid reports whether the instance is a known node kind.
+func (k NodeKind) IsValid() bool {
+	_, ok := nodeKindStrings[k]
+	return ok
+}
+
+// IsLeafKind reports whether nodes of the kind must be leaves.
+func (k NodeKind) IsLeafKind() bool {
+	switch k {
+	case KindPrefix, KindWildcard, KindRange, KindRegex, KindExists, KindURL, KindEmail, KindIdentifier:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,38 @@
+package gossip
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNodeKindString(t *testing.T) {
+	assert.Equal(t, "text", KindText.String())
+	assert.Equal(t, "prefix", KindPrefix.String())
+	assert.Equal(t, "wildcard", KindWildcard.String())
+	assert.Equal(t, "range", KindRange.String())
+	assert.Equal(t, "regex", KindRegex.String())
+	assert.Equal(t, "near", KindNear.String())
+	assert.Equal(t, "exists", KindExists.String())
+	assert.Equal(t, "url", KindURL.String())
+	assert.Equal(t, "email", KindEmail.String())
+	assert.Equal(t, "identifier", KindIdentifier.String())
+	assert.Equal(t, "_error", NodeKind(-1).String())
+}
+
+func TestNodeKindIsValid(t *testing.T) {
+	assert.True(t, KindText.IsValid())
+	assert.True(t, KindWildcard.IsValid())
+	assert.False(t, NodeKind(-1).IsValid())
+}
+
+func TestNodeKindIsLeafKind(t *testing.T) {
+	assert.False(t, KindText.IsLeafKind())
+	assert.True(t, KindPrefix.IsLeafKind())
+	assert.True(t, KindWildcard.IsLeafKind())
+	assert.True(t, KindRange.IsLeafKind())
+	assert.True(t, KindRegex.IsLeafKind())
+	assert.False(t, KindNear.IsLeafKind())
+	assert.True(t, KindExists.IsLeafKind())
+	assert.True(t, KindURL.IsLeafKind())
+}
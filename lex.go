@@ -0,0 +1,776 @@
+package gossip
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+// TokenKind identifies the lexical class of a Token.
+type TokenKind int
+
+// Token kinds produced by a Lexer.
+const (
+	TokenEOF         TokenKind = iota // End of input.
+	TokenTerm                         // Bare term, such as golang.
+	TokenPhrase                       // Phrase literal, such as "data science".
+	TokenVerb                         // Modal verb, such as +.
+	TokenGroupStart                   // Start of a subquery, [ or (.
+	TokenGroupEnd                     // End of a subquery, ] or ).
+	TokenSeparator                    // Run of separators, such as spaces.
+	TokenOperator                     // Keyword operator, such as AND.
+	TokenField                        // Field qualifier, such as title:.
+	TokenPrefix                       // Prefix term, such as mach*.
+	TokenWildcard                     // Wildcard term, such as colo?r.
+	TokenFuzzy                        // Fuzziness or slop suffix, such as ~2.
+	TokenBoost                        // Boost suffix, such as ^2.
+	TokenRange                        // Range query, such as [2010 TO 2020] or >=10.
+	TokenRegex                        // Regular expression literal, such as /go(lang)?/.
+	TokenMinShould                    // Minimum should match suffix, such as @2 or @75%.
+	TokenURL                          // Recognized URL, such as https://example.com/a-b.
+	TokenEmail                        // Recognized email address, such as ops+alerts@corp.io.
+	TokenIdentifier                   // Recognized version-like identifier, such as foo-bar_v2.3.
+	TokenGroupPrefix                  // Group prefix setting the verb of a subquery's clauses, such as &.
+	TokenExact                        // Exact-match prefix of a term or phrase, such as =.
+)
+
+var tokenKindStrings = map[TokenKind]string{
+	TokenEOF:         "EOF",
+	TokenTerm:        "term",
+	TokenPhrase:      "phrase",
+	TokenVerb:        "verb",
+	TokenGroupStart:  "group start",
+	TokenGroupEnd:    "group end",
+	TokenSeparator:   "separator",
+	TokenOperator:    "operator",
+	TokenField:       "field",
+	TokenPrefix:      "prefix",
+	TokenWildcard:    "wildcard",
+	TokenFuzzy:       "fuzzy",
+	TokenBoost:       "boost",
+	TokenRange:       "range",
+	TokenRegex:       "regex",
+	TokenMinShould:   "min should",
+	TokenURL:         "url",
+	TokenEmail:       "email",
+	TokenIdentifier:  "identifier",
+	TokenGroupPrefix: "group prefix",
+	TokenExact:       "exact",
+}
+
+func (k TokenKind) String() string {
+	if ks, ok := tokenKindStrings[k]; ok {
+		return ks
+	}
+	return "_error"
+}
+
+// Token is a lexical unit of a search query together with its source span.
+type Token struct {
+	Kind  TokenKind
+	Text  string // Source text of the token, including any delimiters.
+	Value string // Unescaped term, phrase or field name, prefix, wildcard or regular expression pattern or suffix argument, or the source text otherwise.
+	Start int    // Byte offset of the token in the query.
+	End   int    // Byte offset just past the end of the token.
+}
+
+// Lexer splits a search query into a stream of tokens.  It applies the
+// same rune rules as Parse, so an invalid sequence of reserved runes
+// results in a *ParseError.
+type Lexer struct {
+	p     *Parser
+	input string
+	pos   int
+	err   error
+	last  TokenKind // kind of the last token, or TokenEOF initially
+
+	// Lenient lexers repair problems instead of failing.
+	lenient bool
+	diags   []Diagnostic
+	dropped int  // offset just past the last dropped run of runes
+	prev    rune // rune preceding the last dropped run of runes
+}
+
+// NewLexer creates a lexer for the input query in the default dialect.
+func NewLexer(s string) *Lexer {
+	return defaultParser.Lexer(s)
+}
+
+// Lexer creates a lexer for the input query in the parser's dialect.
+func (p *Parser) Lexer(s string) *Lexer {
+	return &Lexer{p: p, input: s, dropped: -1}
+}
+
+// lenientLexer creates a lexer that records problems as diagnostics
+// and repairs them instead of failing.
+func (p *Parser) lenientLexer(s string) *Lexer {
+	l := p.Lexer(s)
+	l.lenient = true
+	return l
+}
+
+// repair records the error as a diagnostic if the lexer is lenient.
+// Otherwise the error is returned.
+func (l *Lexer) repair(err *ParseError, repair Repair) error {
+	if !l.lenient {
+		return err
+	}
+	l.diags = append(l.diags, Diagnostic{ParseError: err, Repair: repair})
+	return nil
+}
+
+// subsume removes the diagnostics of empty clauses at offset i.  They
+// are nested in an enclosing clause that is empty at the same offset, as
+// in [[[, so only its diagnostic is kept.
+func (l *Lexer) subsume(i int) {
+	diags := l.diags[:0]
+	for _, d := range l.diags {
+		if d.Offset != i || !errors.Is(d.ParseError, ErrEmptyQuery) {
+			diags = append(diags, d)
+		}
+	}
+	l.diags = diags
+}
+
+// drop skips the rune at the current position.
+func (l *Lexer) drop(width int) {
+	if l.dropped != l.pos {
+		l.prev = l.before()
+	}
+	l.pos += width
+	l.dropped = l.pos
+}
+
+// before returns the rune preceding the current position in the input,
+// which is literal if it is escaped, or utf8.RuneError at the start.
+func (l *Lexer) before() rune {
+	r, w := utf8.DecodeLastRuneInString(l.input[:l.pos])
+	switch {
+	case w == 0:
+		return utf8.RuneError
+	case isEscaped(l.input, l.pos-w):
+		return literal
+	}
+	return r
+}
+
+// check determines if the reserved rune at the current position is in a
+// valid sequence, ignoring any runes dropped immediately before it.  In a
+// dialect created with WithClauseStartVerbs, a verb rune ending a term is
+// literal text, and so is checked as such.
+func (l *Lexer) check(r rune, width int) bool {
+	prev := l.prev
+	if l.dropped != l.pos {
+		prev = l.before()
+	}
+	if l.p.clauseVerbs && l.last == TokenTerm && l.p.IsRuneVerb(prev) {
+		prev = literal
+	} else if l.dropped != l.pos {
+		return l.p.checkReserved(l.input, r, l.pos, width)
+	}
+	next, _ := utf8.DecodeRuneInString(l.input[l.pos+width:])
+	return l.p.IsTripleValid(prev, r, next)
+}
+
+// checkVerb determines if the verb rune at the current position is in a
+// valid sequence like check.  In a dialect created with
+// WithClauseStartVerbs, where pairs are also valid with the verb read as
+// literal text, the runes around it are checked with the verb read as a
+// verb.
+func (l *Lexer) checkVerb(r rune, width int) bool {
+	if !l.p.clauseVerbs {
+		return l.check(r, width)
+	}
+	prev := l.prev
+	if l.dropped != l.pos {
+		prev = l.before()
+	}
+	next, _ := utf8.DecodeRuneInString(l.input[l.pos+width:])
+	return l.p.pairValid(prev, r) && l.p.pairValid(r, next)
+}
+
+// Lex splits the input query into tokens.  The final token is always
+// of kind TokenEOF unless an error is returned.
+func Lex(s string) ([]Token, error) {
+	return defaultParser.Lex(s)
+}
+
+// Lex splits the input query into tokens in the parser's dialect.
+// See the Lex function.
+func (p *Parser) Lex(s string) ([]Token, error) {
+	var (
+		l      = p.Lexer(s)
+		tokens []Token
+	)
+	for {
+		tok, err := l.Next()
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, tok)
+		if tok.Kind == TokenEOF {
+			return tokens, nil
+		}
+	}
+}
+
+// Next returns the next token in the input.  Once the input is exhausted,
+// every call returns a TokenEOF token.  Once an error is encountered,
+// every call returns that error.
+func (l *Lexer) Next() (Token, error) {
+	if l.err != nil {
+		return Token{}, l.err
+	}
+
+	tok, err := l.next()
+	if err != nil {
+		l.err = err
+		return Token{}, err
+	}
+	l.pos = tok.End
+	l.last = tok.Kind
+	return tok, nil
+}
+
+func (l *Lexer) next() (Token, error) {
+	for {
+		s, i := l.input, l.pos
+		if i >= len(s) {
+			return Token{Kind: TokenEOF, Start: len(s), End: len(s)}, nil
+		}
+
+		r, width := utf8.DecodeRuneInString(s[i:]) // Get next rune.
+
+		// Ranges directly follow a field qualifier.
+		if l.last == TokenField && l.dropped != i {
+			if j := l.rangeEnd(i); j != -1 {
+				return l.lexRange(j)
+			}
+		}
+
+		// Minimum should match suffixes directly follow a subquery, and
+		// are dropped by lenient lexers if malformed.
+		if l.p.IsMinShould(r) && l.last == TokenGroupEnd && l.dropped != i {
+			j := l.scanNumber(i+width, false)
+			if j != -1 && j < len(s) && s[j] == '%' {
+				j++
+			}
+			if j == -1 || !l.terminated(j) {
+				err := newParseError(s, i, ErrMalformedModifier, "number", "percentage")
+				if err := l.repair(err, RepairDropped); err != nil {
+					return Token{}, err
+				}
+				l.drop(width)
+				continue
+			}
+			tok := l.token(TokenMinShould, j)
+			tok.Value = s[i+width : j]
+			return tok, nil
+		}
+
+		// Regular expression literals begin a clause.
+		if l.p.regex != 0 && r == l.p.regex && l.clauseStart() {
+			return l.lexRegex(width)
+		}
+
+		// Group prefixes directly precede a subquery beginning a clause.
+		if l.p.groupPrefix(r) != 0 && l.clauseStart() {
+			if next, _ := utf8.DecodeRuneInString(s[i+width:]); l.p.IsSubqueryStart(next) {
+				return l.token(TokenGroupPrefix, i+width), nil
+			}
+		}
+
+		// Exact-match prefixes directly precede a term or phrase literal
+		// beginning a clause.
+		if l.p.isExact(r) && l.clauseStart() && l.exactPrefixed(i+width) {
+			return l.token(TokenExact, i+width), nil
+		}
+
+		// Recognized terms begin a clause, and reserved runes are literal
+		// in them.
+		if l.p.recognize && (l.clauseStart() || l.last == TokenExact) {
+			if tok, ok := l.lexRecognized(); ok {
+				return tok, nil
+			}
+		}
+
+		switch {
+		// Lenient lexers drop malformed suffixes.
+		case l.p.IsFuzzy(r) && l.modifiable(), l.p.IsBoost(r) && l.boostable():
+			kind := TokenFuzzy
+			if l.p.IsBoost(r) {
+				kind = TokenBoost
+			}
+			j := l.number(i+width, kind == TokenBoost)
+			if j == -1 {
+				err := newParseError(s, i, ErrMalformedModifier, "number")
+				if err := l.repair(err, RepairDropped); err != nil {
+					return Token{}, err
+				}
+				l.drop(width)
+				continue
+			}
+			tok := l.token(kind, j)
+			tok.Value = s[i+width : j]
+			return tok, nil
+
+		// A phrase directly following a subquery is accepted by lenient
+		// lexers, as is one directly following a term ending in an
+		// exact-match prefix rune.  Directly following a term, it is part
+		// of the term.
+		case l.p.phraseEnd(r) != utf8.RuneError:
+			prefixed := l.last == TokenExact || !l.p.isExact(l.before())
+			if !l.check(r, width) || !prefixed {
+				err := newParseError(s, i, ErrUnexpectedReservedRune)
+				if err := l.repair(err, RepairSeparated); err != nil {
+					return Token{}, err
+				}
+			}
+			return l.lexPhrase(r, width)
+
+		// Lenient lexers drop misplaced verbs.  A field qualifier does not
+		// begin a clause for WithClauseStartVerbs, so a verb rune after it
+		// begins a term.
+		case l.p.IsRuneVerb(r) && l.p.clauseVerbs && l.last == TokenField:
+			return l.lexTerm(i)
+
+		case l.p.IsRuneVerb(r):
+			// If we already remember a verb, the query is malformed.  So
+			// is a suffix rune that directly follows a term, but does not
+			// begin a suffix.
+			if !l.checkVerb(r, width) || l.p.IsFuzzy(r) && l.afterTerm() {
+				err := newParseError(s, i, ErrVerbSequence, "term", "phrase", "subquery")
+				if err := l.repair(err, RepairDropped); err != nil {
+					return Token{}, err
+				}
+				l.drop(width)
+				continue
+			}
+			return l.token(TokenVerb, i+width), nil
+
+		// A subquery directly following a subquery is accepted by lenient
+		// lexers, as is one directly following a term ending in a group
+		// prefix rune.  A subquery that is never closed is handled by Parse.
+		case l.p.IsSubqueryStart(r):
+			prefixed := l.last == TokenGroupPrefix || l.p.groupPrefix(l.before()) == 0
+			if !l.check(r, width) || !prefixed {
+				err := newParseError(s, i, ErrUnexpectedReservedRune)
+				if !l.lenient {
+					return Token{}, err
+				}
+				if i+width < len(s) {
+					_ = l.repair(err, RepairSeparated)
+				}
+			}
+			return l.token(TokenGroupStart, i+width), nil
+
+		// A misplaced subquery end is unpaired, which is handled by Parse.
+		case l.p.IsSubqueryEnd(r):
+			if !l.check(r, width) && !l.lenient {
+				return Token{}, newParseError(s, i, ErrUnexpectedReservedRune)
+			}
+			return l.token(TokenGroupEnd, i+width), nil
+
+		case l.p.IsSeparator(r):
+			// Bad separators are currently detected by other tests.
+			j := i + width
+			for j < len(s) {
+				r, width = utf8.DecodeRuneInString(s[j:])
+				if !l.p.IsSeparator(r) {
+					break
+				}
+				j += width
+			}
+			return l.token(TokenSeparator, j), nil
+
+		// Reserved runes without a role here, such as the end of a phrase
+		// literal, are treated as literal text by lenient lexers.
+		case l.p.IsReserved(r) && !IsEscape(r):
+			err := newParseError(s, i, ErrUnexpectedReservedRune)
+			if err := l.repair(err, RepairLiteral); err != nil {
+				return Token{}, err
+			}
+			return l.lexTerm(i + width)
+		}
+
+		return l.lexTerm(i)
+	}
+}
+
+// exactPrefixed reports whether offset j directly follows an exact-match
+// prefix, namely whether a term or phrase literal begins there.  Terms
+// cannot begin with another prefix rune or a regular expression
+// delimiter, which are literal after the prefix.
+func (l *Lexer) exactPrefixed(j int) bool {
+	r, width := utf8.DecodeRuneInString(l.input[j:])
+	switch {
+	case width == 0, l.p.isExact(r), l.p.regex != 0 && r == l.p.regex:
+		return false
+	}
+	return !l.p.IsReserved(r) || IsEscape(r) || l.p.phraseEnd(r) != utf8.RuneError
+}
+
+// afterTerm reports whether the rune preceding the current position,
+// ignoring any dropped runes, is part of a term.
+func (l *Lexer) afterTerm() bool {
+	prev := l.prev
+	if l.dropped != l.pos {
+		prev = l.before()
+	}
+	return prev != utf8.RuneError && !l.p.IsReserved(prev)
+}
+
+// modifiable reports whether a suffix at the current position directly
+// follows a term or phrase literal.
+func (l *Lexer) modifiable() bool {
+	switch l.last {
+	case TokenTerm, TokenPhrase, TokenPrefix, TokenWildcard:
+		return l.dropped != l.pos
+	}
+	return false
+}
+
+// boostable reports whether a boost at the current position directly
+// follows a clause other than a field qualifier, or another suffix.
+func (l *Lexer) boostable() bool {
+	switch l.last {
+	case TokenFuzzy, TokenGroupEnd, TokenRange, TokenRegex, TokenMinShould, TokenURL, TokenEmail, TokenIdentifier:
+		return l.dropped != l.pos
+	}
+	return l.modifiable()
+}
+
+// clauseStart reports whether a clause can begin at the current position.
+func (l *Lexer) clauseStart() bool {
+	switch l.last {
+	case TokenEOF, TokenSeparator, TokenGroupStart, TokenVerb, TokenOperator, TokenField:
+		return true
+	}
+	return false
+}
+
+// number returns the offset just past the unsigned number starting at
+// offset i, which has a fractional part only if frac is true.  The
+// number must be followed by the end of the input or an unescaped
+// reserved rune.  Otherwise, number returns -1.
+func (l *Lexer) number(i int, frac bool) int {
+	j := l.scanNumber(i, frac)
+	if j == -1 || !l.terminated(j) {
+		return -1
+	}
+	return j
+}
+
+// scanNumber returns the offset just past the unsigned number starting
+// at offset i like number, regardless of what follows it.
+func (l *Lexer) scanNumber(i int, frac bool) int {
+	s := l.input
+	j := i
+	digits := func() {
+		for j < len(s) && '0' <= s[j] && s[j] <= '9' {
+			j++
+		}
+	}
+
+	digits()
+	if j == i {
+		return -1
+	}
+	if frac && j < len(s) && s[j] == '.' {
+		j++
+		k := j
+		digits()
+		if j == k {
+			return -1
+		}
+	}
+	return j
+}
+
+// terminated reports whether offset j is the end of the input or an
+// unescaped reserved rune.
+func (l *Lexer) terminated(j int) bool {
+	if j >= len(l.input) {
+		return true
+	}
+	r, _ := utf8.DecodeRuneInString(l.input[j:])
+	return l.p.IsReserved(r) && !IsEscape(r)
+}
+
+// token creates a token of the input kind spanning from the current
+// position to end.
+func (l *Lexer) token(kind TokenKind, end int) Token {
+	text := l.input[l.pos:end]
+	return Token{Kind: kind, Text: text, Value: text, Start: l.pos, End: end}
+}
+
+// lexPhrase lexes the phrase literal started by the rune r at the
+// current position.  The literal consists of the substring between the
+// opening quotation mark and the next unescaped closing quotation mark.
+func (l *Lexer) lexPhrase(r rune, width int) (Token, error) {
+	s, i := l.input, l.pos+width
+	end := l.p.phraseEnd(r)
+	j := indexUnescaped(s[i:], end)
+	if j == -1 {
+		// Lenient lexers treat the quotation mark as part of a term.
+		err := newParseError(s, l.pos, ErrUnpairedQuotation, "closing "+string(end))
+		if err := l.repair(err, RepairLiteral); err != nil {
+			return Token{}, err
+		}
+		return l.lexTerm(i)
+	}
+	j += i // point j to loc in s of matched quotation mark
+
+	tok := l.token(TokenPhrase, j+utf8.RuneLen(end))
+	tok.Value, _ = unescape(s[i:j])
+	return tok, nil
+}
+
+// lexRegex lexes the regular expression literal starting at the current
+// position.  The literal consists of the substring between the opening
+// delimiter and the next unescaped delimiter, in which escaped
+// delimiters are unescaped.  Other escapes belong to the pattern.
+func (l *Lexer) lexRegex(width int) (Token, error) {
+	s, i, d := l.input, l.pos+width, l.p.regex
+	j := indexUnescaped(s[i:], d)
+	if j == -1 {
+		// Lenient lexers treat the delimiter as part of a term.
+		err := newParseError(s, l.pos, ErrMalformedRegex, "closing "+string(d))
+		if err := l.repair(err, RepairLiteral); err != nil {
+			return Token{}, err
+		}
+		return l.lexTerm(l.pos)
+	}
+	j += i // point j to loc in s of the closing delimiter
+
+	end := j + utf8.RuneLen(d)
+	if err := l.followed(end); err != nil {
+		return Token{}, err
+	}
+	tok := l.token(TokenRegex, end)
+	tok.Value = strings.ReplaceAll(s[i:j], string(Escape)+string(d), string(d))
+	return tok, nil
+}
+
+// lexRecognized lexes the URL, email address or identifier starting at
+// the current position, if any.  The term extends to the next rune that
+// ends a recognized term, and must be recognized in its entirety.
+func (l *Lexer) lexRecognized() (Token, bool) {
+	s := l.input
+	j := strings.IndexFunc(s[l.pos:], l.p.endsRecognized)
+	if j == -1 {
+		j = len(s)
+	} else {
+		j += l.pos
+	}
+
+	var kind TokenKind
+	switch recognize(s[l.pos:j]) {
+	case KindURL:
+		kind = TokenURL
+	case KindEmail:
+		kind = TokenEmail
+	case KindIdentifier:
+		kind = TokenIdentifier
+	default:
+		return Token{}, false
+	}
+	return l.token(kind, j), true
+}
+
+// lexTerm lexes the bare term starting at the current position.
+// The term extends from i to the next unescaped reserved rune.  Lenient
+// lexers extend the term past reserved runes that cannot follow it.
+// A term followed by a field delimiter is a field qualifier instead,
+// unless it follows a field qualifier or exact-match prefix.
+func (l *Lexer) lexTerm(i int) (Token, error) {
+	s := l.input
+	var j int
+	for {
+		var r rune
+		r, j = l.p.NextReserved(s[i:])
+		if j == -1 {
+			j = len(s)
+			break
+		}
+		j += i
+
+		if l.p.IsFieldDelim(r) {
+			width := utf8.RuneLen(r)
+			if j > l.pos && l.last != TokenField && l.last != TokenExact && l.p.checkReserved(s, r, j, width) {
+				return l.lexField(j, width)
+			}
+			err := newParseError(s, j, ErrUnexpectedReservedRune)
+			if err := l.repair(err, RepairLiteral); err != nil {
+				return Token{}, err
+			}
+			i = j + width
+			continue
+		}
+
+		// Verb runes are literal within a term for WithClauseStartVerbs,
+		// unless they begin a suffix.
+		if l.p.clauseVerbs && l.p.IsRuneVerb(r) && !(l.p.IsFuzzy(r) && l.number(j+utf8.RuneLen(r), false) != -1) {
+			i = j + utf8.RuneLen(r)
+			continue
+		}
+
+		if !l.lenient || !(l.p.IsRuneVerb(r) || l.p.IsPhraseDelim(r) || l.p.IsSubqueryStart(r) || l.p.IsBoost(r)) {
+			break
+		}
+		if l.p.IsFuzzy(r) && l.number(j+utf8.RuneLen(r), false) != -1 ||
+			l.p.IsBoost(r) && l.number(j+utf8.RuneLen(r), true) != -1 {
+			break
+		}
+		_ = l.repair(newParseError(s, j, ErrUnexpectedReservedRune), RepairLiteral)
+		i = j + utf8.RuneLen(r)
+	}
+
+	// A term cannot end in a dangling escape, as in `xyz\`.  Keywords
+	// qualified by a field or exact-match prefix are ordinary terms.
+	tok := l.token(TokenTerm, j)
+	if l.last != TokenField && l.last != TokenExact && l.p.operator(tok.Text) != opNone {
+		tok.Kind = TokenOperator
+		return tok, nil
+	}
+	value, ok := unescape(tok.Text)
+	if !ok {
+		err := newParseError(s, j-1, ErrDanglingEscape, "escaped rune")
+		if err := l.repair(err, RepairDropped); err != nil {
+			return Token{}, err
+		}
+	}
+	tok.Value = value
+	return l.wildcard(tok)
+}
+
+// wildcard converts a term containing unescaped wildcards into a prefix
+// or wildcard token.  A term whose only wildcard is a final * is a prefix
+// term, whose value is the unescaped prefix.  The value of a wildcard term
+// is its pattern, in which only wildcards and escapes are escaped.
+func (l *Lexer) wildcard(tok Token) (Token, error) {
+	var (
+		b       strings.Builder
+		escaped bool
+		n       int  // number of wildcards
+		star    bool // whether the last rune is an unescaped *
+	)
+	for i, r := range tok.Text {
+		star = false
+		switch {
+		case escaped:
+			escaped = false
+			if l.p.isWildcard(r) || IsEscape(r) {
+				b.WriteRune(Escape)
+			}
+		case IsEscape(r):
+			escaped = true
+			continue
+		case l.p.isWildcard(r):
+			if i == 0 && !l.p.leading {
+				err := newParseError(l.input, tok.Start, ErrLeadingWildcard, "term")
+				if err := l.repair(err, RepairLiteral); err != nil {
+					return Token{}, err
+				}
+				return tok, nil
+			}
+			n++
+			star = r == Asterisk
+		}
+		b.WriteRune(r)
+	}
+
+	switch {
+	case n == 0:
+	case n == 1 && star && len(tok.Value) > 1:
+		tok.Kind = TokenPrefix
+		tok.Value = tok.Value[:len(tok.Value)-1]
+	default:
+		tok.Kind = TokenWildcard
+		tok.Value = b.String()
+	}
+	return tok, nil
+}
+
+// lexField lexes the field qualifier starting at the current position,
+// whose name is terminated by the field delimiter at offset i.
+func (l *Lexer) lexField(i int, width int) (Token, error) {
+	tok := l.token(TokenField, i+width)
+	tok.Value, _ = unescape(l.input[l.pos:i])
+	return tok, nil
+}
+
+// rangeEnd returns the offset just past the range query starting at
+// offset i, or -1 if there is none.  A comparison extends to the next
+// separator, subquery end or boost, and an interval to the next bracket
+// or brace that can close it, or the end of the input.  A bracket only
+// starts an interval if two bounds separated by TO follow it, and
+// otherwise starts a subquery.
+func (l *Lexer) rangeEnd(i int) int {
+	s := l.input
+	r, width := utf8.DecodeRuneInString(s[i:])
+	switch r {
+	case '<', '>':
+		j := i + width
+		for j < len(s) {
+			r, width := utf8.DecodeRuneInString(s[j:])
+			if l.p.IsSeparator(r) || l.p.IsSubqueryEnd(r) || l.p.IsBoost(r) {
+				break
+			}
+			j += width
+		}
+		return j
+	case RangeInclusiveStart, RangeExclusiveStart:
+	default:
+		return -1
+	}
+
+	i += width
+	j := strings.IndexAny(s[i:], string([]rune{RangeInclusiveEnd, RangeExclusiveEnd}))
+	if j == -1 && r == RangeInclusiveStart {
+		return -1
+	}
+	end := len(s)
+	if j != -1 {
+		end = i + j
+	}
+
+	// A bracket without exactly a lower bound, TO and an upper bound
+	// begins a subquery instead, and a brace without TO begins a term.
+	words, _ := fields(s[i:end])
+	to := false
+	for _, word := range words {
+		to = to || word == RangeTo
+	}
+	switch {
+	case r == RangeInclusiveStart && (len(words) != 3 || words[1] != RangeTo):
+		return -1
+	case !to:
+		return -1
+	case j == -1:
+		return len(s)
+	}
+	_, width = utf8.DecodeRuneInString(s[end:])
+	return end + width
+}
+
+// lexRange lexes the range query from the current position to end.
+func (l *Lexer) lexRange(end int) (Token, error) {
+	if err := l.followed(end); err != nil {
+		return Token{}, err
+	}
+	return l.token(TokenRange, end), nil
+}
+
+// followed checks that the literal ending at offset end is followed by
+// the end of the input or a reserved rune.  Lenient lexers accept a
+// clause directly following the literal.
+func (l *Lexer) followed(end int) error {
+	s := l.input
+	if !l.terminated(end) {
+		_, width := utf8.DecodeLastRuneInString(s[:end])
+		err := newParseError(s, end-width, ErrUnexpectedReservedRune)
+		return l.repair(err, RepairSeparated)
+	}
+	return nil
+}
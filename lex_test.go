@@ -0,0 +1,269 @@
+package gossip
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLex(t *testing.T) {
+	tests := []struct {
+		in  string
+		out []Token
+	}{
+		{"", []Token{{Kind: TokenEOF}}},
+		{
+			"w",
+			[]Token{
+				{TokenTerm, "w", "w", 0, 1},
+				{TokenEOF, "", "", 1, 1},
+			},
+		},
+		{
+			`"data science" +[math, -hype]`,
+			[]Token{
+				{TokenPhrase, `"data science"`, "data science", 0, 14},
+				{TokenSeparator, " ", " ", 14, 15},
+				{TokenVerb, "+", "+", 15, 16},
+				{TokenGroupStart, "[", "[", 16, 17},
+				{TokenTerm, "math", "math", 17, 21},
+				{TokenSeparator, ", ", ", ", 21, 23},
+				{TokenVerb, "-", "-", 23, 24},
+				{TokenTerm, "hype", "hype", 24, 28},
+				{TokenGroupEnd, "]", "]", 28, 29},
+				{TokenEOF, "", "", 29, 29},
+			},
+		},
+		{
+			`c\+\+ "a \"b\""`,
+			[]Token{
+				{TokenTerm, `c\+\+`, "c++", 0, 5},
+				{TokenSeparator, " ", " ", 5, 6},
+				{TokenPhrase, `"a \"b\""`, `a "b"`, 6, 15},
+				{TokenEOF, "", "", 15, 15},
+			},
+		},
+		{
+			`-title:"go" a\:b`,
+			[]Token{
+				{TokenVerb, "-", "-", 0, 1},
+				{TokenField, "title:", "title", 1, 7},
+				{TokenPhrase, `"go"`, "go", 7, 11},
+				{TokenSeparator, " ", " ", 11, 12},
+				{TokenTerm, `a\:b`, "a:b", 12, 16},
+				{TokenEOF, "", "", 16, 16},
+			},
+		},
+		{
+			`mach* colo?r a\*`,
+			[]Token{
+				{TokenPrefix, "mach*", "mach", 0, 5},
+				{TokenSeparator, " ", " ", 5, 6},
+				{TokenWildcard, "colo?r", "colo?r", 6, 12},
+				{TokenSeparator, " ", " ", 12, 13},
+				{TokenTerm, `a\*`, "a*", 13, 16},
+				{TokenEOF, "", "", 16, 16},
+			},
+		},
+		{
+			`go~1 "a b"~2`,
+			[]Token{
+				{TokenTerm, "go", "go", 0, 2},
+				{TokenFuzzy, "~1", "1", 2, 4},
+				{TokenSeparator, " ", " ", 4, 5},
+				{TokenPhrase, `"a b"`, "a b", 5, 10},
+				{TokenFuzzy, "~2", "2", 10, 12},
+				{TokenEOF, "", "", 12, 12},
+			},
+		},
+		{
+			"[a]^0.5 b~1^2",
+			[]Token{
+				{TokenGroupStart, "[", "[", 0, 1},
+				{TokenTerm, "a", "a", 1, 2},
+				{TokenGroupEnd, "]", "]", 2, 3},
+				{TokenBoost, "^0.5", "0.5", 3, 7},
+				{TokenSeparator, " ", " ", 7, 8},
+				{TokenTerm, "b", "b", 8, 9},
+				{TokenFuzzy, "~1", "1", 9, 11},
+				{TokenBoost, "^2", "2", 11, 13},
+				{TokenEOF, "", "", 13, 13},
+			},
+		},
+		{
+			"y:[1 TO 2]^2 d:<2024-01-01",
+			[]Token{
+				{TokenField, "y:", "y", 0, 2},
+				{TokenRange, "[1 TO 2]", "[1 TO 2]", 2, 10},
+				{TokenBoost, "^2", "2", 10, 12},
+				{TokenSeparator, " ", " ", 12, 13},
+				{TokenField, "d:", "d", 13, 15},
+				{TokenRange, "<2024-01-01", "<2024-01-01", 15, 26},
+				{TokenEOF, "", "", 26, 26},
+			},
+		},
+		{
+			`/a\/b/ c/d`,
+			[]Token{
+				{TokenRegex, `/a\/b/`, "a/b", 0, 6},
+				{TokenSeparator, " ", " ", 6, 7},
+				{TokenTerm, "c/d", "c/d", 7, 10},
+				{TokenEOF, "", "", 10, 10},
+			},
+		},
+		{
+			"a\r\n\t+b\u00a0c",
+			[]Token{
+				{TokenTerm, "a", "a", 0, 1},
+				{TokenSeparator, "\r\n\t", "\r\n\t", 1, 4},
+				{TokenVerb, "+", "+", 4, 5},
+				{TokenTerm, "b", "b", 5, 6},
+				{TokenSeparator, "\u00a0", "\u00a0", 6, 8},
+				{TokenTerm, "c", "c", 8, 9},
+				{TokenEOF, "", "", 9, 9},
+			},
+		},
+		{
+			"[a b]@1 (c)@50%^2 d@e",
+			[]Token{
+				{TokenGroupStart, "[", "[", 0, 1},
+				{TokenTerm, "a", "a", 1, 2},
+				{TokenSeparator, " ", " ", 2, 3},
+				{TokenTerm, "b", "b", 3, 4},
+				{TokenGroupEnd, "]", "]", 4, 5},
+				{TokenMinShould, "@1", "1", 5, 7},
+				{TokenSeparator, " ", " ", 7, 8},
+				{TokenGroupStart, "(", "(", 8, 9},
+				{TokenTerm, "c", "c", 9, 10},
+				{TokenGroupEnd, ")", ")", 10, 11},
+				{TokenMinShould, "@50%", "50%", 11, 15},
+				{TokenBoost, "^2", "2", 15, 17},
+				{TokenSeparator, " ", " ", 17, 18},
+				{TokenTerm, "d@e", "d@e", 18, 21},
+				{TokenEOF, "", "", 21, 21},
+			},
+		},
+		{
+			`=Go -="a b" a=b =AND`,
+			[]Token{
+				{TokenExact, "=", "=", 0, 1},
+				{TokenTerm, "Go", "Go", 1, 3},
+				{TokenSeparator, " ", " ", 3, 4},
+				{TokenVerb, "-", "-", 4, 5},
+				{TokenExact, "=", "=", 5, 6},
+				{TokenPhrase, `"a b"`, "a b", 6, 11},
+				{TokenSeparator, " ", " ", 11, 12},
+				{TokenTerm, "a=b", "a=b", 12, 15},
+				{TokenSeparator, " ", " ", 15, 16},
+				{TokenExact, "=", "=", 16, 17},
+				{TokenTerm, "AND", "AND", 17, 20},
+				{TokenEOF, "", "", 20, 20},
+			},
+		},
+		{
+			"日本 語",
+			[]Token{
+				{TokenTerm, "日本", "日本", 0, 6},
+				{TokenSeparator, " ", " ", 6, 7},
+				{TokenTerm, "語", "語", 7, 10},
+				{TokenEOF, "", "", 10, 10},
+			},
+		},
+	}
+
+	for i, tt := range tests {
+		msg := fmt.Sprintf("Fails test case (%d) %s", i, tt.in)
+		tokens, err := Lex(tt.in)
+		assert.NoError(t, err, msg)
+		assert.Equal(t, tt.out, tokens, msg)
+	}
+}
+
+func TestLexKeywords(t *testing.T) {
+	p := NewParser(WithKeywords(DefaultKeywords))
+	tokens, err := p.Lex(`a OR "OR" \OR`)
+	assert.NoError(t, err)
+	kinds := make([]TokenKind, len(tokens))
+	for i, tok := range tokens {
+		kinds[i] = tok.Kind
+	}
+	assert.Equal(
+		t,
+		[]TokenKind{
+			TokenTerm, TokenSeparator, TokenOperator, TokenSeparator,
+			TokenPhrase, TokenSeparator, TokenTerm, TokenEOF,
+		},
+		kinds,
+	)
+
+	// Keywords are ordinary terms in the default dialect.
+	tokens, err = Lex("OR")
+	assert.NoError(t, err)
+	assert.Equal(t, TokenTerm, tokens[0].Kind)
+
+	// Proximity keywords are followed by their slop.
+	for _, in := range []string{"NEAR/5", "ONEAR/0"} {
+		tokens, err = p.Lex(in)
+		assert.NoError(t, err)
+		assert.Equal(t, TokenOperator, tokens[0].Kind, in)
+	}
+	for _, in := range []string{"NEAR", "NEAR/", "NEAR/x", "NEAR/5/"} {
+		tokens, err = p.Lex(in)
+		assert.NoError(t, err)
+		assert.NotEqual(t, TokenOperator, tokens[0].Kind, in)
+	}
+}
+
+func TestLexFailures(t *testing.T) {
+	tests := []struct {
+		in  string
+		err error
+	}{
+		{`"unpaired`, ErrUnpairedQuotation},
+		{`+`, ErrVerbSequence},
+		{`x+`, ErrVerbSequence},
+		{`x[y]`, ErrUnexpectedReservedRune},
+		{`x"y"`, ErrUnexpectedReservedRune},
+		{`x\`, ErrDanglingEscape},
+		{`y:{1 TO 2}x`, ErrUnexpectedReservedRune},
+	}
+
+	for i, tt := range tests {
+		msg := fmt.Sprintf("Fails test case (%d) %s", i, tt.in)
+		tokens, err := Lex(tt.in)
+		assert.Nil(t, tokens, msg)
+		assert.True(t, errors.Is(err, tt.err), msg)
+	}
+}
+
+func TestLexerNextAfterEnd(t *testing.T) {
+	l := NewLexer("x")
+	for i := 0; i < 2; i++ {
+		_, err := l.Next()
+		assert.NoError(t, err)
+	}
+	tok, err := l.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, TokenEOF, tok.Kind)
+}
+
+func TestLexerNextAfterError(t *testing.T) {
+	l := NewLexer("++x")
+	_, err := l.Next()
+	assert.Error(t, err)
+	_, err2 := l.Next()
+	assert.Equal(t, err, err2)
+}
+
+func TestTokenKindString(t *testing.T) {
+	assert.Equal(t, "term", TokenTerm.String())
+	assert.Equal(t, "group start", TokenGroupStart.String())
+	assert.Equal(t, "EOF", TokenEOF.String())
+	assert.Equal(t, "url", TokenURL.String())
+	assert.Equal(t, "identifier", TokenIdentifier.String())
+	assert.Equal(t, "group prefix", TokenGroupPrefix.String())
+	assert.Equal(t, "exact", TokenExact.String())
+	assert.Equal(t, "_error", TokenKind(-1).String())
+}
@@ -1,8 +1,8 @@
 package gossip
 
 import (
-	"fmt"
-	"strings"
+	"math"
+	"regexp/syntax"
 )
 
 // Node in a parsed search tree.  It contains pointers to its parent node,
@@ -11,10 +11,23 @@ import (
 // These fields are exported primarily so that an instance can be marshalled
 // into a JSON string.
 type Node struct {
-	Parent   *Node   `json:"-"`
-	Children []*Node `json:"children,omitempty"`
-	Verb     Verb    `json:"verb,omitempty"`   // Modal verb of the query: must (not), should.
-	Phrase   string  `json:"phrase,omitempty"` // Phrase literal if this query is a leaf.
+	Parent    *Node    `json:"-"`
+	Children  []*Node  `json:"children,omitempty"`
+	Verb      Verb     `json:"verb,omitempty"`       // Modal verb of the query: must (not), should.
+	Implicit  Verb     `json:"implicit,omitempty"`   // Verb of unmarked children set by a group prefix, such as & for must.
+	Exact     bool     `json:"exact,omitempty"`      // Whether a term or phrase is matched case- and analyzer-sensitively, as in =Go.
+	Kind      NodeKind `json:"kind,omitempty"`       // How the query is matched, such as by prefix.
+	Phrase    string   `json:"phrase,omitempty"`     // Phrase literal if this query is a leaf.
+	Field     string   `json:"field,omitempty"`      // Field qualifying the query, if any.
+	Fuzziness int      `json:"fuzziness,omitempty"`  // Maximum edit distance of a fuzzy term.
+	Slop      int      `json:"slop,omitempty"`       // Maximum number of moves between the words of a phrase, or words between near clauses.
+	Ordered   bool     `json:"ordered,omitempty"`    // Whether near clauses must occur in order.
+	Boost     float64  `json:"boost,omitempty"`      // Weight of the query relative to others, if not zero.
+	Range     *Range   `json:"range,omitempty"`      // Interval matched by a range query.
+	MinShould int      `json:"min_should,omitempty"` // Minimum number of Should children that must match, if positive.
+	Percent   bool     `json:"percent,omitempty"`    // Whether MinShould is a percentage of the Should children.
+	Start     int      `json:"start,omitempty"`      // Byte offset of the node in the parsed query.
+	End       int      `json:"end,omitempty"`        // Byte offset just past the node in the parsed query.
 }
 
 // IsLeaf reports whether the node is a leaf, which is equivalent to whether
@@ -30,9 +43,25 @@ func (n *Node) IsLeaf() bool {
 // Conditions that lead to an invalid node are:
 // - The instance is nil.
 // - The instance is its own parent or contains itself as a child.
-// - The instance's verb is not one of the constants Must, Should, MustNot.
-// - The instance is a leaf with an empty phrase.
+// - The instance's verb is not one of the constants Must, Should, MustNot, Filter.
+// - The instance is a leaf with an empty phrase, other than a range or existence query.
 // - The instance is a non-leaf but contains a phrase.
+// - The instance's kind is unknown, or only applies to leaves.
+// - The instance has a negative fuzziness or slop, or has both.
+// - The instance has a fuzziness but is not a KindText leaf.
+// - The instance has a slop but is neither a KindText leaf nor a near clause.
+// - The instance is a near clause without exactly two Must children.
+// - The instance is ordered but is not a near clause.
+// - The instance has an implicit verb other than Must or Not, or is a leaf with one.
+// - The instance is exact but is not a term or phrase leaf, such as a range or regular expression.
+// - The instance has a boost that is negative or not finite.
+// - The instance is a range without a valid range, phrase or no field.
+// - The instance has a range but is not of kind KindRange.
+// - The instance is an existence query with a phrase or without its own field.
+// - The instance is a regular expression whose phrase does not compile.
+// - The instance is a URL, email address or identifier whose phrase is not recognized as such.
+// - The instance has a negative minimum should match, or one above 100 percent.
+// - The instance has a minimum should match above its Should children.
 func (n *Node) IsValid() bool {
 	if n == nil {
 		return false
@@ -42,16 +71,100 @@ func (n *Node) IsValid() bool {
 		return false
 	}
 
-	if !n.Verb.IsValid() {
+	if !n.Verb.IsValid() || !n.Kind.IsValid() {
 		return false
 	}
 
-	if n.IsLeaf() && n.Phrase == "" {
+	if n.IsLeaf() && n.Phrase == "" && n.Kind != KindRange && n.Kind != KindExists {
 		return false
 	}
 
 	// Preliminary non-leaf check.
-	if !n.IsLeaf() && n.Phrase != "" {
+	if !n.IsLeaf() && (n.Phrase != "" || n.Kind.IsLeafKind()) {
+		return false
+	}
+
+	if n.Fuzziness < 0 || n.Slop < 0 || n.Fuzziness > 0 && n.Slop > 0 {
+		return false
+	}
+	if n.Fuzziness > 0 && (!n.IsLeaf() || n.Kind != KindText) {
+		return false
+	}
+	if n.Slop > 0 && (!n.IsLeaf() || n.Kind != KindText) && n.Kind != KindNear {
+		return false
+	}
+
+	// A near clause joins two operands, which must both match.
+	if n.Kind == KindNear {
+		if len(n.Children) != 2 {
+			return false
+		}
+		for _, child := range n.Children {
+			if child.GetVerb() != Must {
+				return false
+			}
+		}
+	}
+	if n.Ordered && n.Kind != KindNear {
+		return false
+	}
+
+	// A group prefix sets the verb of the children of a subquery.
+	if n.Implicit != 0 && (n.Implicit != Must && n.Implicit != Not || n.IsLeaf() || n.Kind != KindText) {
+		return false
+	}
+
+	// Exact matching applies to the words of terms and phrases.
+	if n.Exact {
+		switch {
+		case !n.IsLeaf(), n.Kind == KindRange, n.Kind == KindRegex, n.Kind == KindExists:
+			return false
+		}
+	}
+
+	// A range is matched against the values of a field, not a phrase.
+	if n.Kind == KindRange && (!n.Range.IsValid() || n.Phrase != "" || n.GetField() == "") {
+		return false
+	}
+	if n.Range != nil && n.Kind != KindRange {
+		return false
+	}
+
+	// An existence query checks its own field, which is not inherited.
+	if n.Kind == KindExists && (n.Phrase != "" || n.Field == "") {
+		return false
+	}
+
+	if n.Kind == KindRegex {
+		if _, err := syntax.Parse(n.Phrase, syntax.Perl); err != nil {
+			return false
+		}
+	}
+
+	switch n.Kind {
+	case KindURL, KindEmail, KindIdentifier:
+		if recognize(n.Phrase) != n.Kind {
+			return false
+		}
+	}
+
+	// A zero minimum means any Should child can match, as usual.
+	if n.MinShould < 0 || n.Percent && n.MinShould == 0 {
+		return false
+	}
+	if n.MinShould > 0 {
+		should := n.shouldCount()
+		max := should
+		if n.Percent {
+			max = 100
+		}
+		if should == 0 || n.MinShould > max {
+			return false
+		}
+	}
+
+	// A zero boost means the node is not boosted.
+	if n.Boost < 0 || math.IsInf(n.Boost, 0) || math.IsNaN(n.Boost) {
 		return false
 	}
 
@@ -68,9 +181,10 @@ func (n *Node) IsValid() bool {
 // Conditions that lead to an invalid node are:
 // - The instance is nil.
 // - The instance is its own parent or contains itself as a child.
-// - The instance's verb is not one of the constants Must, Should, MustNot.
-// - The instance is a leaf with an empty phrase.
+// - The instance's verb is not one of the constants Must, Should, MustNot, Filter.
+// - The instance is a leaf with an empty phrase, other than a range or existence query.
 // - The instance is a non-leaf but contains a phrase.
+// - The instance fails any other condition listed by IsValid.
 // - Any child is invalid.
 func (n *Node) IsTreeValid() bool {
 	if !n.IsValid() {
@@ -102,7 +216,8 @@ func (n *Node) GetChildren() []*Node {
 
 // Tree sets the Parent field of the instance's descents to the appropriate
 // node.  This effectively creates a subtree with the instance as a root,
-// although the instance's Parent is not removed.
+// although the instance's Parent is not removed.  All other fields, such
+// as the source spans, are left untouched.
 func (n *Node) Tree() *Node {
 	if n == nil || n.IsLeaf() {
 		return n
@@ -123,6 +238,30 @@ func (n *Node) GetPhrase() string {
 	return n.Phrase
 }
 
+// GetField returns the field the node is scoped to.  A node without a
+// field of its own inherits the field of its nearest qualified ancestor,
+// so every clause of `title:[go rust]` is scoped to title.  The empty
+// string means the node is not scoped to a field.
+func (n *Node) GetField() string {
+	for ; n != nil; n = n.Parent {
+		if n.Field != "" {
+			return n.Field
+		}
+	}
+	return ""
+}
+
+// Span reports the byte offsets in the parsed query of the text that
+// produced the node, including any verb prefix and subquery brackets.
+// The node covers the query substring s[start:end].  Nodes that were not
+// produced by Parse have an empty span.
+func (n *Node) Span() (start, end int) {
+	if n == nil {
+		return 0, 0
+	}
+	return n.Start, n.End
+}
+
 // GetVerb returns the integer code the the modal verb associated to the node.
 func (n *Node) GetVerb() Verb {
 	if n == nil {
@@ -164,6 +303,163 @@ func (n *Node) SetVerb(verb Verb) *Node {
 	return n
 }
 
+// GetKind is a helper get method defined for all instances.
+func (n *Node) GetKind() NodeKind {
+	if n == nil {
+		return KindText
+	}
+	return n.Kind
+}
+
+// SetKind sets the node's kind and returns the instance.
+func (n *Node) SetKind(kind NodeKind) *Node {
+	if n == nil {
+		n = NewNode()
+	}
+	n.Kind = kind
+	return n
+}
+
+// SetField sets the node's field qualifier and returns the instance.
+func (n *Node) SetField(field string) *Node {
+	if n == nil {
+		n = NewNode()
+	}
+	n.Field = field
+	return n
+}
+
+// SetFuzziness sets the maximum edit distance of a fuzzy term and
+// returns the instance.
+func (n *Node) SetFuzziness(fuzziness int) *Node {
+	if n == nil {
+		n = NewNode()
+	}
+	n.Fuzziness = fuzziness
+	return n
+}
+
+// SetSlop sets the maximum number of moves between the words of a phrase
+// and returns the instance.
+func (n *Node) SetSlop(slop int) *Node {
+	if n == nil {
+		n = NewNode()
+	}
+	n.Slop = slop
+	return n
+}
+
+// SetNear makes the node a near clause, whose two children must occur
+// within slop words of each other, and in order if ordered is true.  It
+// returns the instance.
+func (n *Node) SetNear(slop int, ordered bool) *Node {
+	if n == nil {
+		n = NewNode()
+	}
+	n.Kind = KindNear
+	n.Slop = slop
+	n.Ordered = ordered
+	return n
+}
+
+// SetBoost sets the node's boost and returns the instance.
+func (n *Node) SetBoost(boost float64) *Node {
+	if n == nil {
+		n = NewNode()
+	}
+	n.Boost = boost
+	return n
+}
+
+// SetRange sets the interval matched by a range query and returns the
+// instance.  The kind of the node is set to KindRange.
+func (n *Node) SetRange(r *Range) *Node {
+	if n == nil {
+		n = NewNode()
+	}
+	n.Kind = KindRange
+	n.Range = r
+	return n
+}
+
+// SetExists makes the node an existence query, matching documents in
+// which the input field is present, and returns the instance.
+func (n *Node) SetExists(field string) *Node {
+	if n == nil {
+		n = NewNode()
+	}
+	n.Kind = KindExists
+	n.Field = field
+	return n
+}
+
+// SetImplicit sets the verb of the children of a subquery marked by a
+// group prefix, Must or Not, and returns the instance.  A zero verb
+// removes the prefix.  The verbs of the children are left unchanged.
+func (n *Node) SetImplicit(v Verb) *Node {
+	if n == nil {
+		n = NewNode()
+	}
+	n.Implicit = v
+	return n
+}
+
+// SetExact marks a term or phrase leaf as matched case- and
+// analyzer-sensitively, or not, and returns the instance.
+func (n *Node) SetExact(ok bool) *Node {
+	if n == nil {
+		n = NewNode()
+	}
+	n.Exact = ok
+	return n
+}
+
+// SetMinShould sets the minimum number of Should children that must
+// match, or their minimum percentage if percent is true, and returns the
+// instance.
+func (n *Node) SetMinShould(min int, percent bool) *Node {
+	if n == nil {
+		n = NewNode()
+	}
+	n.MinShould = min
+	n.Percent = percent
+	return n
+}
+
+// GetMinShould returns the minimum number of Should children of the node
+// that must match, or 0 if there is no minimum.  A percentage is resolved
+// against the number of Should children, rounding down.
+func (n *Node) GetMinShould() int {
+	if n == nil || n.MinShould <= 0 {
+		return 0
+	}
+	if !n.Percent {
+		return n.MinShould
+	}
+	return n.shouldCount() * n.MinShould / 100
+}
+
+// shouldCount returns the number of children with the verb Should.
+func (n *Node) shouldCount() int {
+	var count int
+	for _, child := range n.GetChildren() {
+		if child.Verb == Should {
+			count++
+		}
+	}
+	return count
+}
+
+// SetSpan sets the node's source span and returns the instance.
+func (n *Node) SetSpan(start, end int) *Node {
+	if n == nil {
+		n = NewNode()
+	}
+	n.Start = start
+	n.End = end
+	return n
+}
+
 // IsRoot reports if the non-nil node is the root of a tree.
 func (n *Node) IsRoot() bool {
 	if n == nil {
@@ -220,20 +516,27 @@ func (n *Node) NewChild() *Node {
 }
 
 // Equals reports whether the instance and input define semantically
-// equal parsed subtrees.
+// equal parsed subtrees.  Leaves are compared by their inherited fields,
+// so the leaf of `title:[go]` equals that of `title:go`.
 func (n *Node) Equals(m *Node) bool {
 	if !n.IsValid() || !m.IsValid() {
 		return false
 	}
 
-	if len(n.Children) != len(m.Children) {
+	if len(n.Children) != len(m.Children) || n.Boost != m.Boost ||
+		n.MinShould != m.MinShould || n.Percent != m.Percent || n.Ordered != m.Ordered {
 		return false
 	}
 
 	if n.IsLeaf() && m.IsLeaf() {
-		return n.Phrase == m.Phrase && n.Verb == m.Verb
+		return n.Phrase == m.Phrase && n.Verb == m.Verb && n.Kind == m.Kind && n.Exact == m.Exact &&
+			n.Fuzziness == m.Fuzziness && n.Slop == m.Slop &&
+			n.Range.Equals(m.Range) && n.GetField() == m.GetField()
 	}
 
+	if n.Kind != m.Kind || n.Slop != m.Slop {
+		return false
+	}
 	for i, ni := range n.Children {
 		if !ni.Equals(m.Children[i]) {
 			return false
@@ -294,31 +597,13 @@ func (n *Node) Height() int {
 	return maxDepth
 }
 
+// String converts the tree rooted at the node into a canonical query
+// string, in which every verb is explicit, every phrase is quoted and
+// every subquery is bracketed.  Near clauses are spelled with
+// DefaultKeywords before their bracketed operands, as in
+// ~NEAR/5["breach", "contract"].  Invalid trees yield the empty string.
 func (n *Node) String() string {
-	if n == nil {
-		return ""
-	}
-
-	// Just return the phrase if the root is a leaf.
-	if n.IsLeaf() {
-		if n.IsValid() {
-			return fmt.Sprintf("%s\"%s\"", n.Verb, n.Phrase)
-		}
-		return ""
-	}
-
-	// Otherwise convert each child to a string.  The result might
-	// look something like [+w0 +"phrase1" -[...]]
-	strs := make([]string, len(n.Children))
-	for i, child := range n.Children {
-		substring := child.String()
-		if substring == "" {
-			return ""
-		}
-		strs[i] = substring
-	}
-
-	return fmt.Sprintf("%s[%s]", n.Verb, strings.Join(strs, ", "))
+	return formatter{p: defaultParser, canonical: true}.format(n)
 }
 
 // NewNode produces a leaf node with the default modal verb of Should,
@@ -2,6 +2,7 @@ package gossip
 
 import (
 	"fmt"
+	"math"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -106,6 +107,142 @@ func TestSetVerbInvalidInput(t *testing.T) {
 	assert.Equal(t, Verb(-10), n.Verb)
 }
 
+func TestSetSpan(t *testing.T) {
+	var n *Node
+	n = n.SetSpan(2, 5)
+	start, end := n.Span()
+	assert.Equal(t, 2, start)
+	assert.Equal(t, 5, end)
+
+	var m *Node
+	start, end = m.Span()
+	assert.Equal(t, 0, start)
+	assert.Equal(t, 0, end)
+}
+
+func TestNodeField(t *testing.T) {
+	var n *Node
+	assert.Equal(t, "", n.GetField())
+	n = n.SetField("title")
+	assert.Equal(t, "title", n.Field)
+
+	// Fields are inherited from the nearest qualified ancestor.
+	c := n.NewChild()
+	g := c.NewChild()
+	assert.Equal(t, "title", c.GetField())
+	assert.Equal(t, "title", g.GetField())
+	c.SetField("body")
+	assert.Equal(t, "body", g.GetField())
+	assert.Equal(t, "", NewNode().GetField())
+}
+
+func TestNodeKind(t *testing.T) {
+	var n *Node
+	assert.Equal(t, KindText, n.GetKind())
+	n = n.SetKind(KindPrefix)
+	assert.Equal(t, KindPrefix, n.GetKind())
+}
+
+func TestSetFuzzinessAndSlop(t *testing.T) {
+	var n *Node
+	n = n.SetFuzziness(2)
+	assert.Equal(t, 2, n.Fuzziness)
+
+	var m *Node
+	m = m.SetSlop(3)
+	assert.Equal(t, 3, m.Slop)
+}
+
+func TestSetBoost(t *testing.T) {
+	var n *Node
+	n = n.SetBoost(1.5)
+	assert.Equal(t, 1.5, n.Boost)
+}
+
+func TestSetRange(t *testing.T) {
+	r := &Range{Type: RangeNumber, Lower: &Bound{Text: "1"}}
+	var n *Node
+	n = n.SetRange(r)
+	assert.Equal(t, KindRange, n.Kind)
+	assert.Equal(t, r, n.Range)
+	assert.False(t, n.IsValid())
+	assert.True(t, n.SetField("year").IsValid())
+}
+
+func TestSetNear(t *testing.T) {
+	var n *Node
+	n = n.SetNear(5, true)
+	assert.Equal(t, KindNear, n.Kind)
+	assert.Equal(t, 5, n.Slop)
+	assert.True(t, n.Ordered)
+	assert.False(t, n.IsValid())
+
+	n.AddChild(&Node{Verb: Must, Phrase: "breach"})
+	n.AddChild(&Node{Verb: Must, Phrase: "contract"})
+	assert.True(t, n.IsValid())
+	assert.True(t, n.SetNear(0, false).IsValid())
+}
+
+func TestSetExists(t *testing.T) {
+	var n *Node
+	n = n.SetExists("abstract")
+	assert.Equal(t, KindExists, n.Kind)
+	assert.Equal(t, "abstract", n.Field)
+	assert.True(t, n.IsValid())
+	assert.False(t, n.SetPhrase("x").IsValid())
+}
+
+func TestSetImplicit(t *testing.T) {
+	var n *Node
+	n = n.SetImplicit(Must)
+	assert.Equal(t, Must, n.Implicit)
+	assert.False(t, n.IsValid())
+
+	n.AddChild(&Node{Verb: Must, Phrase: "a"})
+	assert.True(t, n.IsValid())
+	assert.True(t, n.SetImplicit(Not).IsValid())
+	assert.False(t, n.SetImplicit(Should).IsValid())
+	assert.True(t, n.SetImplicit(0).IsValid())
+}
+
+func TestSetExact(t *testing.T) {
+	var n *Node
+	n = n.SetExact(true).SetPhrase("Go")
+	assert.True(t, n.Exact)
+	assert.True(t, n.IsValid())
+	assert.True(t, n.SetKind(KindPrefix).IsValid())
+	assert.False(t, n.SetKind(KindRegex).IsValid())
+	assert.False(t, NewNode().SetExists("abstract").SetExact(true).IsValid())
+
+	n = NewNode().SetExact(true)
+	n.AddChild(&Node{Verb: Must, Phrase: "a"})
+	assert.False(t, n.IsValid())
+	assert.True(t, n.SetExact(false).IsValid())
+}
+
+func TestMinShould(t *testing.T) {
+	var n *Node
+	n = n.SetMinShould(50, true)
+	assert.Equal(t, 50, n.MinShould)
+	assert.True(t, n.Percent)
+	assert.False(t, n.IsValid())
+	assert.Equal(t, 0, n.GetMinShould())
+
+	for _, phrase := range []string{"a", "b", "c"} {
+		n.AddChild(&Node{Verb: Should, Phrase: phrase})
+	}
+	n.AddChild(&Node{Verb: Must, Phrase: "d"})
+	assert.True(t, n.IsValid())
+	assert.Equal(t, 1, n.GetMinShould())
+	assert.Equal(t, 3, n.SetMinShould(3, false).GetMinShould())
+	assert.False(t, n.SetMinShould(4, false).IsValid())
+	assert.True(t, n.SetMinShould(100, true).IsValid())
+	assert.False(t, n.SetMinShould(101, true).IsValid())
+	assert.False(t, n.SetMinShould(-1, false).IsValid())
+	assert.False(t, n.SetMinShould(0, true).IsValid())
+	assert.Equal(t, 0, (*Node)(nil).GetMinShould())
+}
+
 func TestSetPhrase(t *testing.T) {
 	var n *Node
 	n = n.SetPhrase("0")
@@ -141,6 +278,67 @@ func TestNodeIsValid(t *testing.T) {
 			},
 			false,
 		},
+		{&Node{Verb: Should, Kind: KindPrefix, Phrase: "mach"}, true},
+		{&Node{Verb: Should, Kind: NodeKind(-1), Phrase: "x"}, false},
+		{&Node{Verb: Should, Phrase: "x", Fuzziness: 2}, true},
+		{&Node{Verb: Should, Phrase: "x y", Slop: 2}, true},
+		{&Node{Verb: Should, Phrase: "x", Fuzziness: -1}, false},
+		{&Node{Verb: Should, Phrase: "x", Boost: 0.5}, true},
+		{&Node{Verb: Should, Phrase: "x", Boost: -1}, false},
+		{&Node{Verb: Should, Phrase: "x", Boost: math.Inf(1)}, false},
+		{&Node{Verb: Should, Phrase: "x", Boost: math.NaN()}, false},
+		{&Node{Verb: Should, Phrase: "x", Slop: -1}, false},
+		{&Node{Verb: Should, Phrase: "x", Fuzziness: 1, Slop: 1}, false},
+		{&Node{Verb: Should, Kind: KindPrefix, Phrase: "x", Fuzziness: 1}, false},
+		{
+			&Node{
+				Verb:     Should,
+				Slop:     1,
+				Children: []*Node{&Node{Verb: Must, Phrase: "x"}},
+			},
+			false,
+		},
+		{&Node{Verb: Should, Kind: KindRange, Field: "y", Range: &Range{Type: RangeNumber, Lower: &Bound{Text: "1"}}}, true},
+		{&Node{Verb: Should, Kind: KindRange, Range: &Range{Type: RangeNumber, Lower: &Bound{Text: "1"}}}, false},
+		{&Node{Verb: Should, Kind: KindRange, Field: "y"}, false},
+		{&Node{Verb: Should, Kind: KindRange, Field: "y", Range: &Range{Type: RangeDate, Lower: &Bound{Text: "1"}}}, false},
+		{&Node{Verb: Should, Kind: KindRange, Field: "y", Phrase: "x", Range: &Range{Type: RangeNumber, Lower: &Bound{Text: "1"}}}, false},
+		{&Node{Verb: Should, Phrase: "x", Range: &Range{Type: RangeNumber, Lower: &Bound{Text: "1"}}}, false},
+		{&Node{Verb: Should, Kind: KindRegex, Phrase: "go(lang)?"}, true},
+		{&Node{Verb: Should, Kind: KindRegex, Phrase: "go(lang"}, false},
+		{&Node{Verb: Should, Kind: KindNear, Phrase: "x", Slop: 1}, false},
+		{&Node{Verb: Not, Kind: KindExists, Field: "author"}, true},
+		{&Node{Verb: Not, Kind: KindExists}, false},
+		{&Node{Verb: Should, Kind: KindEmail, Phrase: "ops+alerts@corp.io"}, true},
+		{&Node{Verb: Should, Kind: KindEmail, Phrase: "golang"}, false},
+		{&Node{Verb: Should, Kind: KindIdentifier, Phrase: "https://example.com"}, false},
+		{&Node{Verb: Not, Kind: KindExists, Field: "author", Fuzziness: 1}, false},
+		{&Node{Verb: Should, Phrase: "x", Ordered: true}, false},
+		{
+			&Node{
+				Verb:     Should,
+				Kind:     KindNear,
+				Slop:     1,
+				Children: []*Node{&Node{Verb: Must, Phrase: "x"}},
+			},
+			false,
+		},
+		{
+			&Node{
+				Verb:     Should,
+				Kind:     KindNear,
+				Children: []*Node{&Node{Verb: Must, Phrase: "x"}, &Node{Verb: Should, Phrase: "y"}},
+			},
+			false,
+		},
+		{
+			&Node{
+				Verb:     Should,
+				Kind:     KindWildcard,
+				Children: []*Node{&Node{Verb: Must, Phrase: "x"}},
+			},
+			false,
+		},
 	}
 
 	for i, tt := range tests {
@@ -156,9 +354,12 @@ func TestNodeTree(t *testing.T) {
 	c00 := NewNode()
 	c0.Children = []*Node{c00}
 	r.Children = []*Node{c0}
+	c0.SetSpan(1, 4)
 	r.Tree()
 	assert.Equal(t, c00.Parent, c0)
 	assert.Equal(t, c0.Parent, r)
+	assert.Equal(t, 1, c0.Start)
+	assert.Equal(t, 4, c0.End)
 }
 
 func TestNodeEquals(t *testing.T) {
@@ -176,6 +377,35 @@ func TestNodeEquals(t *testing.T) {
 		{&Node{Verb: Must, Phrase: "x"}, &Node{Verb: Should, Phrase: "x"}, false},
 		{&Node{Verb: Must, Phrase: "x"}, &Node{Verb: Not, Phrase: "x"}, false},
 		{&Node{Phrase: "x", Verb: Should}, &Node{Phrase: "x", Verb: Should}, true},
+		{&Node{Phrase: "x", Verb: Should}, &Node{Phrase: "x", Verb: Should, Kind: KindPrefix}, false},
+		{&Node{Phrase: "x", Verb: Should}, &Node{Phrase: "x", Verb: Should, Fuzziness: 1}, false},
+		{&Node{Phrase: "x", Verb: Should, Slop: 1}, &Node{Phrase: "x", Verb: Should, Slop: 1}, true},
+		{&Node{Phrase: "x", Verb: Should}, &Node{Phrase: "x", Verb: Should, Boost: 2}, false},
+		{
+			NewNode().AddChild(&Node{Verb: Should, Phrase: "x"}).SetMinShould(1, false),
+			NewNode().AddChild(&Node{Verb: Should, Phrase: "x"}),
+			false,
+		},
+		{
+			NewNode().AddChild(&Node{Verb: Must, Phrase: "x"}).AddChild(&Node{Verb: Must, Phrase: "y"}).SetNear(1, false),
+			NewNode().AddChild(&Node{Verb: Must, Phrase: "x"}).AddChild(&Node{Verb: Must, Phrase: "y"}).SetNear(1, false),
+			true,
+		},
+		{
+			NewNode().AddChild(&Node{Verb: Must, Phrase: "x"}).AddChild(&Node{Verb: Must, Phrase: "y"}).SetNear(1, false),
+			NewNode().AddChild(&Node{Verb: Must, Phrase: "x"}).AddChild(&Node{Verb: Must, Phrase: "y"}).SetNear(2, false),
+			false,
+		},
+		{
+			NewNode().AddChild(&Node{Verb: Must, Phrase: "x"}).AddChild(&Node{Verb: Must, Phrase: "y"}).SetNear(1, false),
+			NewNode().AddChild(&Node{Verb: Must, Phrase: "x"}).AddChild(&Node{Verb: Must, Phrase: "y"}).SetNear(1, true),
+			false,
+		},
+		{
+			NewNode().AddChild(&Node{Verb: Must, Phrase: "x"}).AddChild(&Node{Verb: Must, Phrase: "y"}).SetNear(1, false),
+			NewNode().AddChild(&Node{Verb: Must, Phrase: "x"}).AddChild(&Node{Verb: Must, Phrase: "y"}),
+			false,
+		},
 		// 9. Basic test with children.
 		{
 			&Node{
@@ -428,6 +658,18 @@ func TestNodeString(t *testing.T) {
 	c2.NewChild().SetPhrase("v").SetVerb(Must)
 	c2.NewChild().SetPhrase("w").SetVerb(Not)
 
+	h4 := NewNode()
+	h4.NewChild().SetPhrase(`say "hi"`)
+	h4.NewChild().SetPhrase(`a\b`)
+
+	h5 := NewNode().SetField("title")
+	h5.NewChild().SetPhrase("x")
+	h5.NewChild().SetPhrase("y").SetField("a:b")
+
+	h6 := NewNode().SetBoost(3)
+	h6.NewChild().SetPhrase("x")
+	h6.NewChild().SetPhrase("y")
+
 	tests := []struct {
 		in  *Node
 		out string
@@ -437,6 +679,15 @@ func TestNodeString(t *testing.T) {
 		{h1, ""},
 		{h2, `~[~"x", ~"y"]`},
 		{h3, `~[~[~"x", ~"y"], ~[+"v", -"w"]]`},
+		{h4, `~[~"say \"hi\"", ~"a\\b"]`},
+		{h5, `~title:[~"x", ~a\:b:"y"]`},
+		{NewNode().SetPhrase("x").SetField("title"), `~title:"x"`},
+		{NewNode().SetPhrase("c++").SetKind(KindPrefix), `~c\+\+*`},
+		{NewNode().SetPhrase(`a\*?`).SetKind(KindWildcard), `~a\*?`},
+		{NewNode().SetPhrase("c++").SetFuzziness(1), `~c\+\+~1`},
+		{NewNode().SetPhrase("c++").SetSlop(1), `~"c++"~1`},
+		{NewNode().SetPhrase("x").SetBoost(0.25), `~"x"^0.25`},
+		{h6, `~[~"x", ~"y"]^3`},
 	}
 
 	for i, tt := range tests {
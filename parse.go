@@ -1,7 +1,7 @@
 package gossip
 
 import (
-	"errors"
+	"strconv"
 	"strings"
 	"unicode/utf8"
 )
@@ -14,107 +14,541 @@ import (
 // `[[golang]]` is semantically identical to `golang`, and this function
 // returns the height 0 tree for the later.
 //
+// Each node records the span of the query text that produced it,
+// including any verb prefix and subquery brackets.  The root of a
+// tree of positive height spans the entire query.
+//
 // Semantically empty search phrases will yield a parse error.
+// Errors are of type *ParseError and wrap one of the sentinel errors,
+// such as ErrUnpairedQuotation.
 func Parse(s string) (*Node, error) {
+	return defaultParser.Parse(s)
+}
+
+// Parse converts a raw text search written in the parser's dialect into
+// a structured query term tree.  See the Parse function.
+func (p *Parser) Parse(s string) (*Node, error) {
+	return parse(p.Lexer(s))
+}
+
+// ParseLenient converts a raw text search into a structured query term
+// tree like Parse, but repairs malformed queries instead of failing.
+// Misplaced reserved runes are treated as literal text or dropped,
+// unclosed subqueries are closed at the end of the query, and empty
+// phrases and subqueries are dropped.  For example, `c++ golang` yields
+// the terms c++ and golang.
+//
+// Each repair is described by a diagnostic.  Nested clauses that are
+// empty at the same offset, as in [[[, are described once.  If nothing
+// meaningful remains after repairs, the returned node is nil and the
+// final diagnostic is not repaired.
+func ParseLenient(s string) (*Node, []Diagnostic) {
+	return defaultParser.ParseLenient(s)
+}
+
+// ParseLenient converts a raw text search written in the parser's
+// dialect into a structured query term tree, repairing malformed queries
+// instead of failing.  See the ParseLenient function.
+func (p *Parser) ParseLenient(s string) (*Node, []Diagnostic) {
+	lex := p.lenientLexer(s)
+	root, _ := parse(lex)
+	return root, lex.diags
+}
+
+// operator is a keyword operator.
+type operator int
+
+// Keyword operators.
+const (
+	opNone operator = iota
+	opAnd
+	opOr
+	opNot
+	opNear
+	opONear
+)
+
+// proximity states if the operator joins its operands into a near clause.
+func (o operator) proximity() bool {
+	return o == opNear || o == opONear
+}
+
+// frame is a subquery being parsed, or the top level of the query.
+type frame struct {
+	node     *Node
+	start    int        // offset of the subquery start
+	end      rune       // rune closing the subquery
+	implicit Verb       // verb of unmarked children
+	ops      []operator // operator preceding each child
+	explicit []bool     // whether each child has an explicit verb
+	keywords bool       // whether any children are joined by an operator
+}
+
+// add appends a child clause preceded by the operator.
+func (f *frame) add(child *Node, op operator, explicit bool) {
+	f.node.AddChild(child)
+	f.ops = append(f.ops, op)
+	f.explicit = append(f.explicit, explicit)
+	f.keywords = f.keywords || op != opNone
+}
+
+// near replaces the last child with a near clause of it and the input
+// child.  The near clause takes the verb of the last child, and both
+// operands must match.
+func (f *frame) near(child *Node, slop int, ordered bool) {
+	i := len(f.node.Children) - 1
+	left := f.node.Children[i]
+	extend(left)
+
+	n := &Node{Verb: left.Verb, Kind: KindNear, Slop: slop, Ordered: ordered, Start: left.Start, End: child.End}
+	left.Verb, child.Verb = Must, Must
+	n.AddChild(left).AddChild(child)
+	n.Parent = f.node
+	f.node.Children[i] = n
+}
+
+// extend sets the end of a near clause to that of its last operand,
+// which grows with the suffixes and subquery end parsed after the clause
+// was created.
+func extend(n *Node) {
+	if n.Kind != KindNear || n.IsLeaf() {
+		return
+	}
+	last := n.Children[len(n.Children)-1]
+	extend(last)
+	n.End = last.End
+}
+
+// parse builds a query tree from the tokens produced by the lexer.
+// Problems are reported through the lexer, so lenient lexers repair them.
+func parse(lex *Lexer) (*Node, error) {
 	var (
-		currVerb Verb  = Should // modal verb to apply to children
-		i        int            // current index in input string
-		root     *Node = NewNode()
-		curr     *Node = root
+		s        string = lex.input
+		root     *Node  = NewNode().SetSpan(0, len(s))
+		frames   []*frame
+		curr     *frame
+		currVerb Verb          // modal verb to apply to the next child
+		explicit bool          // whether currVerb was given explicitly
+		negated  bool          // whether currVerb was given by NOT
+		op       operator      // operator preceding the next child
+		opTok    Token         // token of op, or of NOT
+		slop     int           // slop of op if it is a proximity operator
+		field    Token         // field qualifying the next child, if any
+		clause   *Node         // clause of the last term, phrase or subquery, if any
+		implicit Verb          // verb of the next subquery's clauses set by a group prefix, if any
+		exact    bool          // whether the next term or phrase follows an exact-match prefix
+		quoted   bool          // whether clause is a phrase literal
+		start    int      = -1 // offset of the verb applied to the next child
 	)
 
+	// add appends a clause to the current frame, or joins it to the last
+	// clause if it follows a proximity operator.
+	add := func(q *Node) {
+		if !op.proximity() {
+			curr.add(q, op, explicit)
+			return
+		}
+		curr.near(q, slop, op == opONear)
+	}
+	push := func(node *Node, i int, end rune) {
+		curr = &frame{node: node, start: i, end: end, implicit: lex.p.implicitVerb(len(frames))}
+		if node.Implicit != 0 {
+			curr.implicit = node.Implicit
+		}
+		frames = append(frames, curr)
+	}
+	reset := func() {
+		currVerb, explicit, negated, op, field, start = curr.implicit, false, false, opNone, Token{}, -1
+		implicit, exact = 0, false
+	}
+	// pending reports an operator, NOT keyword or field missing its operand.
+	pending := func() error {
+		if field.Kind == TokenField {
+			err := newParseError(s, field.End-1, ErrUnexpectedReservedRune, "term", "phrase", "subquery")
+			if err := lex.repair(err, RepairDropped); err != nil {
+				return err
+			}
+		}
+		if op == opNone && !negated {
+			return nil
+		}
+		err := newParseError(s, opTok.Start, ErrOperatorSequence, "term", "phrase", "subquery")
+		return lex.repair(err, RepairDropped)
+	}
+
+	push(root, 0, utf8.RuneError)
+	reset()
+
 	if s == "" {
-		return nil, errors.New(ErrorEmptyQuery)
+		err := newParseError(s, 0, ErrEmptyQuery, "term", "phrase", "subquery")
+		return nil, lex.repair(err, RepairNone)
 	}
 
-	for i < len(s) {
-		r, width := utf8.DecodeRuneInString(s[i:]) // Get next rune.
+	for {
+		tok, err := lex.Next()
+		if err != nil {
+			return nil, err
+		}
 
-		switch {
-		// When we see a quotation mark, search for the next occurrence and
-		// create a child
-		case IsPhraseDelim(r):
-			if !checkReserved(s, r, i, width) {
-				return nil, errors.New(ErrorMalformedQuery)
+		// A child spans from its verb, if any, to the end of its last token.
+		if start == -1 {
+			start = tok.Start
+		}
+
+		switch tok.Kind {
+		case TokenEOF:
+			if err := pending(); err != nil {
+				return nil, err
+			}
+			for len(frames) > 1 {
+				perr := newParseError(s, curr.start, ErrUnpairedBracket, "closing "+string(curr.end))
+				if err := lex.repair(perr, RepairClosed); err != nil {
+					return nil, err
+				}
+				curr.node.End = len(s)
+				if err := closeSubquery(lex, curr, len(s)); err != nil {
+					return nil, err
+				}
+				frames = frames[:len(frames)-1]
+				curr = frames[len(frames)-1]
+			}
+			curr.lower()
+			return collapse(lex, root)
+
+		case TokenPhrase:
+			q := &Node{Verb: currVerb, Phrase: tok.Value, Field: field.Value, Exact: exact, Start: start, End: tok.End}
+			clause, quoted = nil, true
+			if q.IsValid() {
+				add(q)
+				clause = q
+			} else {
+				err := newParseError(s, tok.Start, ErrEmptyQuery, "phrase")
+				if err := lex.repair(err, RepairDropped); err != nil {
+					return nil, err
+				}
+			}
+			reset()
+
+		case TokenTerm, TokenPrefix, TokenWildcard, TokenURL, TokenEmail, TokenIdentifier:
+			clause, quoted = nil, false
+			if tok.Value != "" {
+				q := &Node{Verb: currVerb, Phrase: tok.Value, Field: field.Value, Exact: exact, Start: start, End: tok.End}
+				switch {
+				case tok.Kind == TokenPrefix:
+					q.Kind = KindPrefix
+				case tok.Kind == TokenWildcard:
+					q.Kind = KindWildcard
+				case tok.Kind == TokenURL:
+					q.Kind = KindURL
+				case tok.Kind == TokenEmail:
+					q.Kind = KindEmail
+				case tok.Kind == TokenIdentifier:
+					q.Kind = KindIdentifier
+				case field.Kind == TokenField && lex.p.isExists(field.Value) && !exact:
+					// The term qualified by an existence field names the
+					// field, unless it is matched exactly.
+					q.Kind, q.Phrase, q.Field = KindExists, "", tok.Value
+				}
+				add(q)
+				clause = q
+			}
+			reset()
+
+		// The lexer ensures a range follows a field qualifier.  Malformed
+		// bounds are dropped by lenient lexers.
+		case TokenRange:
+			clause, quoted = nil, false
+			rng, i, err := newRange(tok.Text)
+			if err != nil {
+				perr := newParseError(s, tok.Start+i, ErrMalformedRange, "number", "date")
+				if err := lex.repair(perr, RepairDropped); err != nil {
+					return nil, err
+				}
+			} else {
+				q := &Node{Verb: currVerb, Kind: KindRange, Field: field.Value, Range: rng, Start: start, End: tok.End}
+				add(q)
+				clause = q
 			}
+			reset()
 
-			// Create a leaf query consisting the substring between the matched
-			// quotation and the next unescaped quotation mark.
-			i += width
-			j := strings.IndexRune(s[i:], Quote)
-			if j == -1 {
-				return nil, errors.New(ErrorUnpairedQuotation)
+		// Patterns that do not compile are dropped by lenient lexers.
+		case TokenRegex:
+			clause, quoted = nil, false
+			q := &Node{Verb: currVerb, Kind: KindRegex, Phrase: tok.Value, Field: field.Value, Start: start, End: tok.End}
+			if q.IsValid() {
+				add(q)
+				clause = q
+			} else {
+				err := newParseError(s, tok.Start, ErrMalformedRegex, "regular expression")
+				if tok.Value == "" {
+					err = newParseError(s, tok.Start, ErrEmptyQuery, "regular expression")
+				}
+				if err := lex.repair(err, RepairDropped); err != nil {
+					return nil, err
+				}
 			}
-			j += i // point j to loc in s of matched quotation mark
+			reset()
 
-			q := &Node{
-				Verb:   currVerb,
-				Phrase: s[i:j],
+		// The lexer ensures suffixes directly follow the clause they
+		// modify.  Fuzziness applies to terms, and slop to phrase literals.
+		case TokenFuzzy:
+			n, err := strconv.Atoi(tok.Value)
+			if clause == nil || clause.Kind != KindText || err != nil {
+				perr := newParseError(s, tok.Start, ErrMalformedModifier)
+				if err := lex.repair(perr, RepairDropped); err != nil {
+					return nil, err
+				}
+				start = -1
+				continue
 			}
-			if !q.IsValid() {
-				return nil, errors.New(ErrorEmptyQuery)
+			if quoted {
+				clause.Slop = n
+			} else {
+				clause.Fuzziness = n
 			}
-			curr.AddChild(q)
+			clause.End = tok.End
+			start = -1
 
-			// Update state.
-			currVerb = Should
-			i = j + width // advance head past matching quotation mark
+		case TokenBoost:
+			b, err := strconv.ParseFloat(tok.Value, 64)
+			if clause == nil || err != nil || b <= 0 {
+				perr := newParseError(s, tok.Start, ErrMalformedModifier, "positive number")
+				if err := lex.repair(perr, RepairDropped); err != nil {
+					return nil, err
+				}
+				start = -1
+				continue
+			}
+			clause.Boost = b
+			clause.End = tok.End
+			start = -1
 
-		case IsRuneVerb(r):
-			// Update state.  If we already remember a verb, the query is malformed.
-			if !checkReserved(s, r, i, width) {
-				return nil, errors.New(ErrorVerbSequence)
+		// The lexer ensures a minimum directly follows a subquery, whose
+		// Should children it cannot exceed.
+		case TokenMinShould:
+			n, err := strconv.Atoi(strings.TrimSuffix(tok.Value, "%"))
+			if clause != nil && err == nil && n > 0 {
+				clause.MinShould, clause.Percent = n, strings.HasSuffix(tok.Value, "%")
+				if !clause.IsValid() {
+					clause.MinShould, clause.Percent = 0, false
+				}
+			}
+			if clause == nil || clause.MinShould == 0 {
+				perr := newParseError(s, tok.Start, ErrMalformedModifier, "number of should clauses")
+				if err := lex.repair(perr, RepairDropped); err != nil {
+					return nil, err
+				}
+				start = -1
+				continue
 			}
-			currVerb = Verb(r)
-			i += width
+			clause.End = tok.End
+			start = -1
 
-		// Replace the current node with a new child subquery node.
-		case IsSubqueryStart(r):
-			if !checkReserved(s, r, i, width) {
-				return nil, errors.New(ErrorMalformedQuery)
+		case TokenVerb:
+			// The lexer catches verb sequences, but not a verb after NOT.
+			// The operands of a near clause cannot have verbs of their own.
+			if explicit || op.proximity() {
+				err := newParseError(s, tok.Start, ErrVerbSequence, "term", "phrase", "subquery")
+				if err := lex.repair(err, RepairDropped); err != nil {
+					return nil, err
+				}
+				continue
 			}
-			child := &Node{Verb: currVerb}
-			curr.AddChild(child)
-			curr = child
-			i += width
-			currVerb = Should
+			r, _ := utf8.DecodeRuneInString(tok.Text)
+			currVerb = lex.p.runeVerb(r)
+			explicit = true
 
-		case IsSubqueryEnd(r):
-			if !checkReserved(s, r, i, width) {
-				return nil, errors.New(ErrorMalformedQuery)
+		// AND, OR and the proximity operators join two clauses, while NOT
+		// applies the verb Not.
+		case TokenOperator:
+			kw := lex.p.operator(tok.Text)
+			ok := !explicit && (kw == opNot && !op.proximity() || kw != opNot && op == opNone && len(curr.node.Children) > 0)
+			if !ok {
+				err := newParseError(s, tok.Start, ErrOperatorSequence, "term", "phrase", "subquery")
+				if err := lex.repair(err, RepairDropped); err != nil {
+					return nil, err
+				}
+				start = -1
+				continue
 			}
-			if !curr.IsValid() {
-				return nil, errors.New(ErrorMalformedQuery)
+
+			// The slop of a proximity operator must fit in an int.
+			if kw.proximity() {
+				n, err := strconv.Atoi(tok.Text[strings.LastIndexByte(tok.Text, byte(NearSlop))+1:])
+				if err != nil {
+					perr := newParseError(s, tok.Start, ErrMalformedModifier, "number")
+					if err := lex.repair(perr, RepairDropped); err != nil {
+						return nil, err
+					}
+					start = -1
+					continue
+				}
+				slop = n
+			}
+
+			opTok = tok
+			if kw != opNot {
+				op = kw
+				start = -1
+				continue
 			}
-			curr = curr.GetParent()
-			i += width
+			if op == opNone && len(curr.node.Children) > 0 {
+				op = opAnd
+			}
+			currVerb, explicit, negated = Not, true, true
 
-		case IsSeparator(r):
-			// Bad separators are currently detected by other tests.
-			// if !checkReserved(s, r, i, width) {
-			// 	return nil, errors.New(ErrorMalformedQuery)
-			// }
-			i += width
+		// The lexer ensures a field is followed by the clause it qualifies.
+		case TokenField:
+			field = tok
 
-		default:
-			// No reserved runes detected.  Search for next space.
-			_, j := NextReserved(s[i:])
-			if j == -1 {
-				j = len(s)
-			} else {
-				j += i
+		// The lexer ensures a group prefix is followed by a subquery.
+		case TokenGroupPrefix:
+			r, _ := utf8.DecodeRuneInString(tok.Text)
+			implicit = lex.p.groupPrefix(r)
+
+		// The lexer ensures an exact-match prefix is followed by a term or
+		// phrase literal.
+		case TokenExact:
+			exact = true
+
+		// Replace the current node with a new child subquery node.
+		case TokenGroupStart:
+			child := &Node{Verb: currVerb, Field: field.Value, Implicit: implicit, Start: start}
+			add(child)
+			r, _ := utf8.DecodeRuneInString(tok.Text)
+			push(child, tok.Start, lex.p.groupEnd(r))
+			reset()
+
+		case TokenGroupEnd:
+			clause = nil
+			if len(frames) == 1 {
+				err := newParseError(s, tok.Start, ErrUnpairedBracket)
+				if err := lex.repair(err, RepairDropped); err != nil {
+					return nil, err
+				}
+				start = -1
+				continue
+			}
+			// Lenient lexers close the open subquery with any end rune.
+			if r, _ := utf8.DecodeRuneInString(tok.Text); r != curr.end {
+				err := newParseError(s, tok.Start, ErrMismatchedBracket, "closing "+string(curr.end))
+				if err := lex.repair(err, RepairMatched); err != nil {
+					return nil, err
+				}
+			}
+			if err := pending(); err != nil {
+				return nil, err
 			}
+			curr.node.End = tok.End
+			if err := closeSubquery(lex, curr, tok.Start); err != nil {
+				return nil, err
+			}
+			if curr.node.IsValid() {
+				clause = curr.node
+			}
+			frames = frames[:len(frames)-1]
+			curr = frames[len(frames)-1]
+			reset()
+
+		case TokenSeparator:
+			start = -1
+		}
+	}
+}
 
-			// This will add the node with phrase xyz for the bad
-			// query xyz+, but the error will be caught in the next check.
-			_ = curr.AddChild(&Node{Verb: currVerb, Phrase: s[i:j]})
+// closeSubquery lowers and checks the subquery ending at offset i.
+// Empty subqueries are dropped by lenient lexers.
+func closeSubquery(lex *Lexer, f *frame, i int) error {
+	f.lower()
+	if f.node.IsValid() {
+		return nil
+	}
+
+	err := newParseError(lex.input, i, ErrEmptyQuery, "term", "phrase", "subquery")
+	lex.subsume(i)
+	if err := lex.repair(err, RepairDropped); err != nil {
+		return err
+	}
+	parent := f.node.GetParent()
+	parent.Children = parent.Children[:len(parent.Children)-1]
+
+	// A near clause missing its right operand is replaced by its left.
+	if parent.Kind == KindNear {
+		left := parent.Children[0]
+		left.Verb, left.Parent = parent.Verb, parent.Parent
+		siblings := parent.Parent.Children
+		siblings[len(siblings)-1] = left
+	}
+	return nil
+}
+
+// lower rewrites children joined by keyword operators into clauses with
+// modal verbs.  The children are split into disjuncts by OR.  A disjunct
+// of a single child becomes a Should clause, and a disjunct of several
+// children becomes a Should subquery of Must clauses.  If there is only
+// one disjunct, its children become Must clauses of the frame instead.
+// Explicit verbs are left untouched.  The spans of near clauses are
+// first extended to their last operands.
+func (f *frame) lower() {
+	for _, child := range f.node.Children {
+		extend(child)
+	}
+	if !f.keywords {
+		return
+	}
+
+	// Split the children into disjuncts.
+	var (
+		children  = f.node.Children
+		disjuncts [][]int
+		disjunct  []int
+	)
+	for i := range children {
+		op := f.ops[i]
+		if op == opNone && f.implicit == Must {
+			op = opAnd
+		}
+		if i > 0 && op != opAnd {
+			disjuncts = append(disjuncts, disjunct)
+			disjunct = nil
+		}
+		disjunct = append(disjunct, i)
+	}
+	disjuncts = append(disjuncts, disjunct)
+
+	setVerb := func(i int, v Verb) *Node {
+		if !f.explicit[i] {
+			children[i].Verb = v
+		}
+		return children[i]
+	}
 
-			i = j
-			currVerb = Should
+	f.node.Children = nil
+	for _, d := range disjuncts {
+		switch {
+		case len(disjuncts) == 1:
+			for _, i := range d {
+				f.node.AddChild(setVerb(i, Must))
+			}
+		case len(d) == 1:
+			f.node.AddChild(setVerb(d[0], Should))
+		default:
+			conj := &Node{
+				Verb:  Should,
+				Start: children[d[0]].Start,
+				End:   children[d[len(d)-1]].End,
+			}
+			for _, i := range d {
+				conj.AddChild(setVerb(i, Must))
+			}
+			f.node.AddChild(conj)
 		}
 	}
+}
 
-	// Collapse unnecessary hierarchy, and do a basic sanity check.
+// collapse removes unnecessary hierarchy from the parsed tree,
+// and does a basic sanity check.
+func collapse(lex *Lexer, root *Node) (*Node, error) {
 	if len(root.Children) == 1 && root.Children[0].IsLeaf() {
 		root = root.Children[0]
 		root.Parent = nil
@@ -122,7 +556,10 @@ func Parse(s string) (*Node, error) {
 
 	// Node checks are cheap.  Catches queries like "  ".
 	if !root.IsValid() {
-		return nil, errors.New(ErrorMalformedQuery)
+		s := lex.input
+		err := newParseError(s, len(s), ErrEmptyQuery, "term", "phrase", "subquery")
+		lex.subsume(len(s))
+		return nil, lex.repair(err, RepairNone)
 	}
 
 	return root, nil
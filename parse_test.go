@@ -1,6 +1,7 @@
 package gossip
 
 import (
+	"errors"
 	"fmt"
 	"testing"
 
@@ -15,11 +16,39 @@ func TestParsePasses(t *testing.T) {
 	}{
 		{"w", &Node{Phrase: "w", Verb: Should}},
 		//
+		{`c\+\+`, &Node{Phrase: "c++", Verb: Should}},
+		//
+		{`+"say \"hi\""`, &Node{Phrase: `say "hi"`, Verb: Must}},
+		//
+		{`"back\\slash"`, &Node{Phrase: `back\slash`, Verb: Should}},
+		//
+		{
+			`+programming \[c\]`,
+			&Node{
+				Verb: Should,
+				Children: []*Node{
+					{Phrase: "programming", Verb: Must},
+					{Phrase: "[c]", Verb: Should},
+				},
+			},
+		},
+		//
 		{
 			`+"machine learning"`,
 			&Node{Verb: Must, Phrase: "machine learning"},
 		},
 		//
+		{
+			`#lang:go +generics`,
+			&Node{
+				Verb: Should,
+				Children: []*Node{
+					{Phrase: "go", Field: "lang", Verb: Filter},
+					{Phrase: "generics", Verb: Must},
+				},
+			},
+		},
+		//
 		{
 			"x y",
 			&Node{
@@ -163,6 +192,399 @@ func TestParsePasses(t *testing.T) {
 	}
 }
 
+func TestParseParens(t *testing.T) {
+	tests := []struct {
+		in   string
+		same string // query with brackets that parses to the same tree
+	}{
+		{"+(math -hype)", "+[math -hype]"},
+		{`"data science" +(math -hype)`, `"data science" +[math -hype]`},
+		{"((golang))", "[[golang]]"},
+		{"a ([b c] -(d))", "a [[b c] -[d]]"},
+		{`"(a)" \(b\)`, `"(a)" "(b)"`},
+	}
+
+	for i, tt := range tests {
+		msg := fmt.Sprintf("Fails test case (%d) %s", i, tt.in)
+		tree, err := Parse(tt.in)
+		assert.NoError(t, err, msg)
+		same, err := Parse(tt.same)
+		assert.NoError(t, err, msg)
+		assert.True(t, same.Tree().Equals(tree.Tree()), msg)
+	}
+
+	// Parsers given only brackets treat parentheses as ordinary runes.
+	p := NewParser(WithGroupDelims(Brackets))
+	tree, err := p.Parse("f(x) [y]")
+	assert.NoError(t, err)
+	assert.Equal(t, `~[~"f(x)", ~[~"y"]]`, tree.String())
+}
+
+func TestParseFields(t *testing.T) {
+	tests := []struct {
+		in     string
+		out    string
+		fields []string // Inherited fields of the leaves.
+	}{
+		{"title:golang", `~title:"golang"`, []string{"title"}},
+		{`author:"Rob Pike"`, `~author:"Rob Pike"`, []string{"author"}},
+		{
+			"go -title:[rust zig]",
+			`~[~"go", -title:[~"rust", ~"zig"]]`,
+			[]string{"", "title", "title"},
+		},
+		{
+			"title:[a body:b]",
+			`~[~title:[~"a", ~body:"b"]]`,
+			[]string{"title", "body"},
+		},
+		{`a\:b`, `~"a:b"`, []string{""}},
+		{`"a:b"`, `~"a:b"`, []string{""}},
+		{`":b"`, `~":b"`, []string{""}},
+		{`x ":b"`, `~[~"x", ~":b"]`, []string{"", ""}},
+		{`title:":b"`, `~title:":b"`, []string{"title"}},
+		{"12:30", `~12:"30"`, []string{"12"}},
+		{`t\[1\]:x`, `~t\[1\]:"x"`, []string{"t[1]"}},
+	}
+
+	for i, tt := range tests {
+		msg := fmt.Sprintf("Fails test case (%d) %s", i, tt.in)
+		tree, err := Parse(tt.in)
+		assert.NoError(t, err, msg)
+		assert.Equal(t, tt.out, tree.String(), msg)
+		leaves := tree.Leaves()
+		if assert.Len(t, leaves, len(tt.fields), msg) {
+			for j, field := range tt.fields {
+				assert.Equal(t, field, leaves[j].GetField(), msg)
+			}
+		}
+	}
+
+	// A leading colon is literal in a term, and formatted as a phrase.
+	tree, err := Parse(`\:b`)
+	assert.NoError(t, err)
+	tree, err = Parse(defaultParser.Format(tree))
+	assert.NoError(t, err)
+	assert.Equal(t, `~":b"`, tree.String())
+
+	// Keywords qualified by a field are terms.
+	p := NewParser(WithKeywords(DefaultKeywords))
+	tree, err = p.Parse("title:AND")
+	assert.NoError(t, err)
+	assert.Equal(t, `~title:"AND"`, tree.String())
+
+	// Parsers without a field delimiter treat colons as ordinary runes.
+	p = NewParser(WithFieldDelim(0))
+	tree, err = p.Parse("title:golang")
+	assert.NoError(t, err)
+	assert.Equal(t, `~"title:golang"`, tree.String())
+}
+
+func TestParseWildcards(t *testing.T) {
+	lead := NewParser(WithLeadingWildcards(true))
+	none := NewParser(WithWildcards(false))
+
+	tests := []struct {
+		p      *Parser
+		in     string
+		kind   NodeKind
+		phrase string
+	}{
+		{defaultParser, "mach*", KindPrefix, "mach"},
+		{defaultParser, "+title:mach*", KindPrefix, "mach"},
+		{defaultParser, `c\+\+*`, KindPrefix, "c++"},
+		{defaultParser, `a\**`, KindPrefix, "a*"},
+		{defaultParser, "colo?r", KindWildcard, "colo?r"},
+		{defaultParser, "a*b*", KindWildcard, "a*b*"},
+		{defaultParser, "ab**", KindWildcard, "ab**"},
+		{defaultParser, `a\*b?`, KindWildcard, `a\*b?`},
+		{defaultParser, `a\\b?`, KindWildcard, `a\\b?`},
+		{defaultParser, `a\+b?`, KindWildcard, "a+b?"},
+		{defaultParser, `mach\*`, KindText, "mach*"},
+		{defaultParser, `"mach*"`, KindText, "mach*"},
+		{defaultParser, `\*ing`, KindText, "*ing"},
+		{lead, "*ing", KindWildcard, "*ing"},
+		{lead, "*", KindWildcard, "*"},
+		{none, "mach*", KindText, "mach*"},
+		{none, "*ing", KindText, "*ing"},
+	}
+
+	for i, tt := range tests {
+		msg := fmt.Sprintf("Fails test case (%d) %s", i, tt.in)
+		tree, err := tt.p.Parse(tt.in)
+		if assert.NoError(t, err, msg) {
+			assert.Equal(t, tt.kind, tree.Kind, msg)
+			assert.Equal(t, tt.phrase, tree.Phrase, msg)
+		}
+	}
+}
+
+func TestParseFuzzy(t *testing.T) {
+	tests := []struct {
+		in        string
+		phrase    string
+		fuzziness int
+		slop      int
+	}{
+		{"golang~2", "golang", 2, 0},
+		{"+golang~0", "golang", 0, 0},
+		{`"data science"~3`, "data science", 0, 3},
+		{`"golang"~1`, "golang", 0, 1},
+		{`title:c\+\+~1`, "c++", 1, 0},
+		{`a\~1`, "a~1", 0, 0},
+		{`"a~1"`, "a~1", 0, 0},
+	}
+
+	for i, tt := range tests {
+		msg := fmt.Sprintf("Fails test case (%d) %s", i, tt.in)
+		tree, err := Parse(tt.in)
+		if assert.NoError(t, err, msg) {
+			assert.Equal(t, tt.phrase, tree.Phrase, msg)
+			assert.Equal(t, tt.fuzziness, tree.Fuzziness, msg)
+			assert.Equal(t, tt.slop, tree.Slop, msg)
+			assert.Equal(t, len(tt.in), tree.End, msg)
+		}
+	}
+
+	// The verb ~ still begins clauses.
+	tree, err := Parse("a~1 ~b [~c~2]")
+	assert.NoError(t, err)
+	assert.Equal(t, `~[~a~1, ~"b", ~[~c~2]]`, tree.String())
+}
+
+func TestParseBoost(t *testing.T) {
+	tree, err := Parse(`golang^3 +[math data]^0.5 "a b"~1^2 (c)^1.25`)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	boosts := make([]float64, len(tree.Children))
+	for i, child := range tree.Children {
+		boosts[i] = child.Boost
+	}
+	assert.Equal(t, []float64{3, 0.5, 2, 1.25}, boosts)
+	assert.Equal(t, 1, tree.Children[2].Slop)
+
+	// Spans include the suffixes.
+	start, end := tree.Children[1].Span()
+	assert.Equal(t, 9, start)
+	assert.Equal(t, 25, end)
+}
+
+func TestParseRanges(t *testing.T) {
+	tree, err := Parse(`year:[2010 TO 2020] -price:>=10 +date:{* TO 2024-01-01}^2 title:[go TO]`)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	year := tree.Children[0]
+	assert.Equal(t, KindRange, year.Kind)
+	assert.Equal(t, "year", year.Field)
+	assert.Equal(t, RangeNumber, year.Range.Type)
+	assert.Equal(t, 2010.0, year.Range.Lower.Number)
+	assert.Equal(t, 2020.0, year.Range.Upper.Number)
+
+	price := tree.Children[1]
+	assert.Equal(t, Not, price.Verb)
+	assert.True(t, price.Range.Lower.Inclusive)
+	assert.Nil(t, price.Range.Upper)
+
+	date := tree.Children[2]
+	assert.Equal(t, RangeDate, date.Range.Type)
+	assert.Nil(t, date.Range.Lower)
+	assert.False(t, date.Range.Upper.Inclusive)
+	assert.Equal(t, 2.0, date.Boost)
+	start, end := date.Span()
+	assert.Equal(t, 32, start)
+	assert.Equal(t, 57, end)
+
+	// Brackets only start a range if they contain two bounds.
+	title := tree.Children[3]
+	assert.Equal(t, KindText, title.Kind)
+	assert.Len(t, title.Children, 2)
+
+	// Braces only start a range if they contain TO.
+	tree, err = Parse(`title:{draft}`)
+	if assert.NoError(t, err) {
+		assert.Equal(t, KindText, tree.Kind)
+		assert.Equal(t, "{draft}", tree.Phrase)
+	}
+	_, err = Parse(`title:{draft TO}`)
+	assert.True(t, errors.Is(err, ErrMalformedRange))
+}
+
+func TestParseRegex(t *testing.T) {
+	tree, err := Parse(`/go(lang)?/ +t:/a\/b/^2 and/or`)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	kinds := make([]NodeKind, len(tree.Children))
+	phrases := make([]string, len(tree.Children))
+	for i, child := range tree.Children {
+		kinds[i] = child.Kind
+		phrases[i] = child.Phrase
+	}
+	assert.Equal(t, []NodeKind{KindRegex, KindRegex, KindText}, kinds)
+	assert.Equal(t, []string{"go(lang)?", "a/b", "and/or"}, phrases)
+	assert.Equal(t, "t", tree.Children[1].Field)
+	assert.Equal(t, 2.0, tree.Children[1].Boost)
+
+	// Reserved runes are literal inside the delimiters.
+	tree, err = Parse(`/[+-]?\d+(,\d+)*/`)
+	assert.NoError(t, err)
+	assert.Equal(t, `[+-]?\d+(,\d+)*`, tree.Phrase)
+}
+
+func TestParseExists(t *testing.T) {
+	tree, err := Parse(`has:abstract -_exists_:author^2 has:"x y" has:x* t:[has:a\:b]`)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	kinds := make([]NodeKind, len(tree.Children))
+	fields := make([]string, len(tree.Children))
+	for i, child := range tree.Children {
+		kinds[i] = child.Kind
+		fields[i] = child.Field
+	}
+	assert.Equal(t, []NodeKind{KindExists, KindExists, KindText, KindPrefix, KindText}, kinds)
+	assert.Equal(t, []string{"abstract", "author", "has", "has", "t"}, fields)
+	assert.Equal(t, "", tree.Children[1].Phrase)
+	assert.Equal(t, Not, tree.Children[1].Verb)
+	assert.Equal(t, 2.0, tree.Children[1].Boost)
+	assert.Equal(t, "a:b", tree.Children[4].Children[0].Field)
+
+	// Existence queries have no phrase to be fuzzy.
+	_, err = Parse("has:x~1")
+	assert.True(t, errors.Is(err, ErrMalformedModifier))
+}
+
+func TestParseGroupPrefixes(t *testing.T) {
+	tests := []struct {
+		in  string
+		out string
+	}{
+		{`&[a b c]`, `~[~&[+"a", +"b", +"c"]]`},
+		{`x ![a b]`, `~[~"x", ~![-"a", -"b"]]`},
+		{`-t:&[a -b]^2`, `~[-t:&[+"a", -"b"]^2]`},
+		{`&[a ![b c]] &(d)`, `~[~&[+"a", +![-"b", -"c"]], ~&[+"d"]]`},
+		{`a&b !x & [a]`, `~[~"a&b", ~"!x", ~"&", ~[~"a"]]`},
+	}
+
+	for i, tt := range tests {
+		msg := fmt.Sprintf("Fails test case (%d) %s", i, tt.in)
+		tree, err := Parse(tt.in)
+		if assert.NoError(t, err, msg) {
+			assert.Equal(t, tt.out, tree.String(), msg)
+		}
+	}
+
+	// Groups record the prefix, and span it.
+	tree, err := Parse("x &[a b]")
+	assert.NoError(t, err)
+	group := tree.Children[1]
+	assert.Equal(t, Must, group.Implicit)
+	assert.Equal(t, []int{2, 8}, []int{group.Start, group.End})
+
+	// A group prefix rune cannot end a term directly before a subquery.
+	_, err = Parse("a&[b]")
+	assert.True(t, errors.Is(err, ErrUnexpectedReservedRune))
+	var perr *ParseError
+	if assert.True(t, errors.As(err, &perr)) {
+		assert.Equal(t, 2, perr.Offset)
+	}
+}
+
+func TestParseExact(t *testing.T) {
+	tests := []struct {
+		in  string
+		out string
+	}{
+		{`=Go`, `~="Go"`},
+		{`="Rob Pike"`, `~="Rob Pike"`},
+		{`+title:=Go -=go* =a~2 ="b c"~3^2`, `~[+title:="Go", -=go*, ~=a~2, ~="b c"~3^2]`},
+		{`[=a] =\=b`, `~[~[~="a"], ~="=b"]`},
+		{`a=b = x ==y =/z/`, `~[~"a=b", ~"=", ~"x", ~"==y", ~"=/z/"]`},
+		{`has:=abstract`, `~has:="abstract"`},
+	}
+
+	for i, tt := range tests {
+		msg := fmt.Sprintf("Fails test case (%d) %s", i, tt.in)
+		tree, err := Parse(tt.in)
+		if assert.NoError(t, err, msg) {
+			assert.Equal(t, tt.out, tree.String(), msg)
+		}
+	}
+
+	// Prefixed leaves span the prefix.
+	tree, err := Parse("x -=Go")
+	assert.NoError(t, err)
+	leaf := tree.Children[1]
+	assert.True(t, leaf.Exact)
+	assert.Equal(t, []int{2, 6}, []int{leaf.Start, leaf.End})
+
+	// The prefix cannot precede a field qualifier or subquery, or end a
+	// term directly before a phrase literal.
+	for _, in := range []string{`=title:Go`, `=[a]`, `a="b"`} {
+		_, err := Parse(in)
+		assert.True(t, errors.Is(err, ErrUnexpectedReservedRune), in)
+	}
+}
+
+func TestParseWhiteSpace(t *testing.T) {
+	tests := []string{
+		"golang\r\nrust +go",
+		"golang\nrust\t+go",
+		"golang\u00a0rust\u3000+go",
+		"\r\n golang,\r\nrust\r\n+go\r\n",
+	}
+
+	for i, tt := range tests {
+		msg := fmt.Sprintf("Fails test case (%d) %q", i, tt)
+		tree, err := Parse(tt)
+		assert.NoError(t, err, msg)
+		assert.Equal(t, `~[~"golang", ~"rust", +"go"]`, tree.String(), msg)
+	}
+
+	// Line breaks in phrase literals are kept.
+	tree, err := Parse("\"a\r\nb\"")
+	assert.NoError(t, err)
+	assert.Equal(t, "a\r\nb", tree.Phrase)
+
+	// Positions count CRLF line breaks as one line.
+	_, err = Parse("golang\r\nrust\r\n++go")
+	var perr *ParseError
+	if assert.True(t, errors.As(err, &perr)) {
+		assert.Equal(t, 14, perr.Offset)
+		assert.Equal(t, 3, perr.Line)
+		assert.Equal(t, 1, perr.Column)
+	}
+}
+
+func TestParseMinShould(t *testing.T) {
+	tree, err := Parse(`[a b c d]@2 +(a b c -d)@75%^2 ops@corp.io`)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	count := tree.Children[0]
+	assert.Equal(t, 2, count.MinShould)
+	assert.False(t, count.Percent)
+	assert.Equal(t, 2, count.GetMinShould())
+
+	percent := tree.Children[1]
+	assert.Equal(t, 75, percent.MinShould)
+	assert.True(t, percent.Percent)
+	assert.Equal(t, 2, percent.GetMinShould())
+	assert.Equal(t, 2.0, percent.Boost)
+	start, end := percent.Span()
+	assert.Equal(t, 12, start)
+	assert.Equal(t, 29, end)
+
+	assert.Equal(t, "ops@corp.io", tree.Children[2].Phrase)
+}
+
 func TestParseFailures(t *testing.T) {
 	// All the tests should raise a parse error.
 	tests := []string{
@@ -195,6 +617,67 @@ func TestParseFailures(t *testing.T) {
 		`+w+ `,               // empty
 		`,`,                  // empty
 		`,,,`,                // empty
+		`dangling\`,
+		`"unpaired\"`,
+		`c\+"x"`,
+		`(`,
+		`()`,
+		`[a)`,
+		`(a]`,
+		`([a)]`,
+		`:x`,
+		`x:`,
+		`x: y`,
+		`x:+y`,
+		`x:-[y]`,
+		`[x:]`,
+		`a:b:c`,
+		`x:""`,
+		`x:[]`,
+		`*ing`,
+		`?x`,
+		`*`,
+		`x t:*`,
+		`a~`,
+		`a~x`,
+		`a~2b`,
+		`a~1.5`,
+		`a~2~3`,
+		`"a"~`,
+		`mach*~2`,
+		`[a]~2`,
+		`a~99999999999999999999`,
+		`a^`,
+		`a^0`,
+		`a^x`,
+		`a^1.`,
+		`a^.5`,
+		`a^2^3`,
+		`^2`,
+		`a ^2`,
+		`[a]^2b`,
+		`year:[2020 TO 2010]`,
+		`year:[1 TO x]`,
+		`year:{1 TO 2`,
+		`year:[* TO *]`,
+		`price:>`,
+		`price:>=x`,
+		`year:[1 TO 2]x`,
+		`year:[1 TO 2]~1`,
+		`/a`,
+		`//`,
+		`/a(/`,
+		`/a/b`,
+		`/a/~1`,
+		`[a b]@3`,
+		`[a +b]@2`,
+		`[a b]@0`,
+		`[a b]@101%`,
+		`[a b]@x`,
+		`[a b]@`,
+		`[a b]@1.5`,
+		`[a b]^2@1`,
+		`[a b]@1@1`,
 	}
 
 	for i, tt := range tests {
@@ -207,6 +690,245 @@ func TestParseFailures(t *testing.T) {
 	}
 }
 
+func TestParseErrorPositions(t *testing.T) {
+	tests := []struct {
+		in     string
+		err    error
+		offset int
+		line   int
+		column int
+	}{
+		{"", ErrEmptyQuery, 0, 1, 1},
+		{`x "no closing`, ErrUnpairedQuotation, 2, 1, 3},
+		{"x\n+ y", ErrVerbSequence, 2, 2, 1},
+		{"c#", ErrVerbSequence, 1, 1, 2},
+		{"a#b", ErrVerbSequence, 1, 1, 2},
+		{"日本 ++x", ErrVerbSequence, 7, 1, 4},
+		{"x ]", ErrUnpairedBracket, 2, 1, 3},
+		{"x [y [z]", ErrUnpairedBracket, 2, 1, 3},
+		{"x []", ErrEmptyQuery, 3, 1, 4},
+		{"x (y", ErrUnpairedBracket, 2, 1, 3},
+		{"x (y]", ErrMismatchedBracket, 4, 1, 5},
+		{"x title: y", ErrUnexpectedReservedRune, 7, 1, 8},
+		{"a:b:c", ErrUnexpectedReservedRune, 3, 1, 4},
+		{"x t:*ing", ErrLeadingWildcard, 4, 1, 5},
+		{`x "a"~b`, ErrMalformedModifier, 5, 1, 6},
+		{"x a*~1", ErrMalformedModifier, 4, 1, 5},
+		{"x [y]^0", ErrMalformedModifier, 5, 1, 6},
+		{"x y:[2 TO 1]", ErrMalformedRange, 10, 1, 11},
+		{"x y:>=z", ErrMalformedRange, 6, 1, 7},
+		{"x /y", ErrMalformedRegex, 2, 1, 3},
+		{"x /y[/", ErrMalformedRegex, 2, 1, 3},
+		{"x [y z]@3", ErrMalformedModifier, 7, 1, 8},
+		{"x [y z]@2x", ErrMalformedModifier, 7, 1, 8},
+		{"[x (y])", ErrMismatchedBracket, 5, 1, 6},
+		{`x ""`, ErrEmptyQuery, 2, 1, 3},
+		{`x\`, ErrDanglingEscape, 1, 1, 2},
+		{"x[y]", ErrUnexpectedReservedRune, 1, 1, 2},
+		{" , ", ErrEmptyQuery, 3, 1, 4},
+	}
+
+	for i, tt := range tests {
+		msg := fmt.Sprintf("Fails test case (%d) %#v", i, tt)
+		_, err := Parse(tt.in)
+		assert.True(t, errors.Is(err, tt.err), msg)
+		assert.True(t, errors.Is(err, ErrMalformedQuery), msg)
+
+		var perr *ParseError
+		if assert.True(t, errors.As(err, &perr), msg) {
+			assert.Equal(t, tt.in, perr.Query, msg)
+			assert.Equal(t, tt.offset, perr.Offset, msg)
+			assert.Equal(t, tt.line, perr.Line, msg)
+			assert.Equal(t, tt.column, perr.Column, msg)
+		}
+	}
+}
+
+func TestParseSpans(t *testing.T) {
+	type span struct{ start, end int }
+	tests := []struct {
+		in    string
+		spans []span // Pre-order traversal of the parsed tree.
+	}{
+		{"w", []span{{0, 1}}},
+		{` +"a b" `, []span{{1, 7}}},
+		{"x -y", []span{{0, 4}, {0, 1}, {2, 4}}},
+		{
+			`"data science" +[math -hype]`,
+			[]span{{0, 28}, {0, 14}, {15, 28}, {17, 21}, {22, 27}},
+		},
+		{"[[x] 日本]", []span{{0, 12}, {0, 12}, {1, 4}, {2, 3}, {5, 11}}},
+		{"-t:x +t:[y]", []span{{0, 11}, {0, 4}, {5, 11}, {9, 10}}},
+	}
+
+	var walk func(n *Node) []span
+	walk = func(n *Node) []span {
+		start, end := n.Span()
+		spans := []span{{start, end}}
+		for _, child := range n.GetChildren() {
+			spans = append(spans, walk(child)...)
+		}
+		return spans
+	}
+
+	for i, tt := range tests {
+		msg := fmt.Sprintf("Fails test case (%d) %s", i, tt.in)
+		tree, err := Parse(tt.in)
+		assert.NoError(t, err, msg)
+		assert.Equal(t, tt.spans, walk(tree.Tree()), msg)
+	}
+}
+
+func TestParseLenient(t *testing.T) {
+	type diag struct {
+		err    error
+		offset int
+		repair Repair
+	}
+	tests := []struct {
+		in    string
+		out   string
+		diags []diag
+	}{
+		{"golang", `~"golang"`, nil},
+		{
+			"c++ golang",
+			`~[~"c++", ~"golang"]`,
+			[]diag{
+				{ErrUnexpectedReservedRune, 1, RepairLiteral},
+				{ErrUnexpectedReservedRune, 2, RepairLiteral},
+			},
+		},
+		{
+			`"abc def`,
+			`~[~"\"abc", ~"def"]`,
+			[]diag{{ErrUnpairedQuotation, 0, RepairLiteral}},
+		},
+		{"a +", `~"a"`, []diag{{ErrVerbSequence, 2, RepairDropped}}},
+		{"++x", `+"x"`, []diag{{ErrVerbSequence, 0, RepairDropped}}},
+		{"a ]", `~"a"`, []diag{{ErrUnpairedBracket, 2, RepairDropped}}},
+		{"y:[2 TO 1] x", `~"x"`, []diag{{ErrMalformedRange, 8, RepairDropped}}},
+		{"/usr x", `~[~"/usr", ~"x"]`, []diag{{ErrMalformedRegex, 0, RepairLiteral}}},
+		{"/a(/ x", `~"x"`, []diag{{ErrMalformedRegex, 0, RepairDropped}}},
+		{"[a b]@3 x", `~[~[~"a", ~"b"], ~"x"]`, []diag{{ErrMalformedModifier, 5, RepairDropped}}},
+		{
+			"y:[1 TO 2]x",
+			`~[~y:[1 TO 2], ~"x"]`,
+			[]diag{{ErrUnexpectedReservedRune, 9, RepairSeparated}},
+		},
+		{
+			"x +[a [b",
+			`~[~"x", +[~"a", ~[~"b"]]]`,
+			[]diag{
+				{ErrUnpairedBracket, 6, RepairClosed},
+				{ErrUnpairedBracket, 3, RepairClosed},
+			},
+		},
+		{
+			`x [""]`,
+			`~"x"`,
+			[]diag{
+				{ErrEmptyQuery, 3, RepairDropped},
+				{ErrEmptyQuery, 5, RepairDropped},
+			},
+		},
+		{`x\`, `~"x"`, []diag{{ErrDanglingEscape, 1, RepairDropped}}},
+		{
+			`[a][b]"c"`,
+			`~[~[~"a"], ~[~"b"], ~"c"]`,
+			[]diag{
+				{ErrUnexpectedReservedRune, 3, RepairSeparated},
+				{ErrUnexpectedReservedRune, 6, RepairSeparated},
+			},
+		},
+		{
+			"x +(a b]",
+			`~[~"x", +[~"a", ~"b"]]`,
+			[]diag{{ErrMismatchedBracket, 7, RepairMatched}},
+		},
+		{
+			"a:b:c title: x",
+			`~[~a:"b:c", ~"title:", ~"x"]`,
+			[]diag{
+				{ErrUnexpectedReservedRune, 3, RepairLiteral},
+				{ErrUnexpectedReservedRune, 11, RepairLiteral},
+			},
+		},
+		{"*ing", `~"*ing"`, []diag{{ErrLeadingWildcard, 0, RepairLiteral}}},
+		{`"a"~ b~1`, `~[~"a", ~b~1]`, []diag{{ErrMalformedModifier, 3, RepairDropped}}},
+		{"[a]^0 b", `~[~[~"a"], ~"b"]`, []diag{{ErrMalformedModifier, 3, RepairDropped}}},
+		{"", "", []diag{{ErrEmptyQuery, 0, RepairNone}}},
+		{
+			"[+]",
+			"",
+			[]diag{
+				{ErrVerbSequence, 1, RepairDropped},
+				{ErrEmptyQuery, 2, RepairDropped},
+				{ErrEmptyQuery, 3, RepairNone},
+			},
+		},
+		{
+			"[[[",
+			"",
+			[]diag{
+				{ErrUnpairedBracket, 2, RepairClosed},
+				{ErrUnpairedBracket, 1, RepairClosed},
+				{ErrUnpairedBracket, 0, RepairClosed},
+				{ErrEmptyQuery, 3, RepairNone},
+			},
+		},
+		{
+			"x [[]] y",
+			`~[~"x", ~"y"]`,
+			[]diag{
+				{ErrEmptyQuery, 4, RepairDropped},
+				{ErrEmptyQuery, 5, RepairDropped},
+			},
+		},
+		{
+			"x [[",
+			`~"x"`,
+			[]diag{
+				{ErrUnpairedBracket, 3, RepairClosed},
+				{ErrUnpairedBracket, 2, RepairClosed},
+				{ErrEmptyQuery, 4, RepairDropped},
+			},
+		},
+	}
+
+	for i, tt := range tests {
+		msg := fmt.Sprintf("Fails test case (%d) %s", i, tt.in)
+		tree, diags := ParseLenient(tt.in)
+		assert.Equal(t, tt.out, tree.String(), msg)
+		if tt.out == "" {
+			assert.Nil(t, tree, msg)
+		}
+		var got []diag
+		for _, d := range diags {
+			got = append(got, diag{d.Err, d.Offset, d.Repair})
+		}
+		assert.Equal(t, tt.diags, got, msg)
+	}
+}
+
+func TestParseLenientAgreesWithParse(t *testing.T) {
+	tests := []string{
+		"w",
+		`"data science" +[math -hype]`,
+		`+"phrase one", [+"phrase 2", -z]`,
+		`c\+\+`,
+	}
+
+	for i, tt := range tests {
+		msg := fmt.Sprintf("Fails test case (%d) %s", i, tt)
+		strict, err := Parse(tt)
+		assert.NoError(t, err, msg)
+		lenient, diags := ParseLenient(tt)
+		assert.Len(t, diags, 0, msg)
+		assert.Equal(t, strict, lenient, msg)
+	}
+}
+
 func ExampleParse() {
 	// Example search that should include the phrases
 	// data science, machine learning and math,
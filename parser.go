@@ -0,0 +1,582 @@
+package gossip
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Delims is a pair of runes that start and end a construct such as
+// a subquery or phrase literal.
+type Delims struct {
+	Start rune
+	End   rune
+}
+
+// Subquery delimiters.  Both are accepted by the default dialect.
+var (
+	Brackets = Delims{LeftBracket, RightBracket}
+	Parens   = Delims{LeftParen, RightParen}
+)
+
+// SmartQuotes are the pairs of typographic and single quotation marks
+// accepted as phrase delimiters by WithSmartQuotes.
+var SmartQuotes = []Delims{
+	{'\u201c', '\u201d'}, // “ ”
+	{'\u00ab', '\u00bb'}, // « »
+	{'\u2018', '\u2019'}, // ‘ ’
+	{'\'', '\''},
+}
+
+// Keywords are the spellings of the boolean keyword operators AND, OR
+// and NOT, and of the proximity operators NEAR and ONEAR.  An empty
+// spelling disables the operator.
+type Keywords struct {
+	And        string
+	Or         string
+	Not        string
+	Near       string // Followed by a slash and the slop, as in NEAR/5.
+	ONear      string // Like Near, but the clauses must occur in order.
+	IgnoreCase bool   // Whether keywords are recognized regardless of case.
+}
+
+// DefaultKeywords are the upper case keyword operators AND, OR, NOT, NEAR
+// and ONEAR.
+var DefaultKeywords = Keywords{And: "AND", Or: "OR", Not: "NOT", Near: "NEAR", ONear: "ONEAR"}
+
+// ExistsFields are the pseudo fields of existence queries in the default
+// dialect.  The term qualified by such a field names a field that must
+// be present, as in has:abstract or _exists_:abstract.
+var ExistsFields = []string{"has", "_exists_"}
+
+// NearSlop separates a proximity keyword from its slop, as in NEAR/5.
+const NearSlop rune = Slash
+
+// Parser parses search queries written in a configurable dialect of the
+// search DSL.  The dialect determines which runes denote verbs,
+// separators, subqueries and phrase literals.  Parsers should be created
+// with NewParser, and are safe for concurrent use.
+type Parser struct {
+	verbs       map[rune]Verb     // verb runes and the verbs they denote
+	separators  map[rune]struct{} // separator runes in addition to white space
+	groups      []Delims          // subquery delimiters
+	phrases     []Delims          // phrase literal delimiters
+	field       rune              // field delimiter, or 0 if disabled
+	fuzzy       rune              // fuzziness and slop suffix rune, or 0 if disabled
+	boost       rune              // boost suffix rune, or 0 if disabled
+	regex       rune              // regular expression delimiter, or 0 if disabled
+	minShould   rune              // minimum should match suffix rune, or 0 if disabled
+	exact       rune              // exact-match prefix rune, or 0 if disabled
+	defaultVerb Verb              // verb applied to unmarked clauses
+	subVerb     Verb              // verb applied to unmarked clauses of subqueries
+	keywords    *Keywords         // keyword operators, if enabled
+	exists      []string          // pseudo fields of existence queries
+	wildcards   bool              // whether bare terms can contain wildcards
+	leading     bool              // whether wildcards can begin a term
+	smart       bool              // whether SmartQuotes delimit phrase literals
+	clauseVerbs bool              // whether verb runes are only verbs at the start of a clause
+	recognize   bool              // whether URLs, email addresses and identifiers are single terms
+	prefixes    map[rune]Verb     // group prefix runes and the implicit verbs they set
+	displaced   map[Verb]rune     // verbs whose rune denotes another verb
+	reserved    map[rune]struct{} // all runes with a special meaning
+}
+
+// Option configures a Parser.
+type Option func(*Parser)
+
+// defaultParser parses the default dialect used by the package level
+// functions, such as Parse.
+var defaultParser = NewParser()
+
+// NewParser creates a parser for the dialect described by the options.
+// Without options, the parser uses the default dialect of Parse.
+// Options are applied in order.  NewParser panics if the resulting
+// dialect assigns more than one role to a rune, or uses a verb that is
+// not valid.
+func NewParser(opts ...Option) *Parser {
+	p := &Parser{
+		verbs: map[rune]Verb{
+			rune(Must):   Must,
+			rune(Not):    Not,
+			rune(Should): Should,
+			rune(Filter): Filter,
+		},
+		separators: map[rune]struct{}{
+			Space: struct{}{},
+			Comma: struct{}{},
+		},
+		prefixes: map[rune]Verb{
+			Ampersand:   Must,
+			Exclamation: Not,
+		},
+		groups:      []Delims{Brackets, Parens},
+		phrases:     []Delims{{PhraseDelim, PhraseDelim}},
+		field:       FieldDelim,
+		fuzzy:       Tilde,
+		boost:       Caret,
+		regex:       Slash,
+		minShould:   At,
+		exact:       EqualsSign,
+		exists:      ExistsFields,
+		wildcards:   true,
+		defaultVerb: Should,
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	if err := p.init(); err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// init builds the reserved rune lookup and validates the dialect.
+func (p *Parser) init() error {
+	if p.smart {
+		for _, d := range SmartQuotes {
+			if p.phraseEnd(d.Start) == utf8.RuneError {
+				p.phrases = append(p.phrases, d)
+			}
+		}
+	}
+
+	p.reserved = map[rune]struct{}{Escape: struct{}{}}
+	add := func(r rune) error {
+		if _, ok := p.reserved[r]; ok || unicode.IsSpace(r) {
+			return fmt.Errorf("gossip: Rune %q has more than one role.", r)
+		}
+		p.reserved[r] = struct{}{}
+		return nil
+	}
+
+	for _, r := range p.displaced {
+		return fmt.Errorf("gossip: Rune %q has more than one role.", r)
+	}
+	for r, v := range p.verbs {
+		if !v.IsValid() {
+			return fmt.Errorf("gossip: Verb %d is not valid.", v)
+		}
+		if err := add(r); err != nil {
+			return err
+		}
+	}
+	for r := range p.separators {
+		// White space is always a separator.
+		if unicode.IsSpace(r) {
+			continue
+		}
+		if err := add(r); err != nil {
+			return err
+		}
+	}
+	for _, d := range p.groups {
+		if err := add(d.Start); err != nil {
+			return err
+		}
+		if err := add(d.End); err != nil {
+			return err
+		}
+	}
+	// Suffixes are told apart from verbs by their position.
+	if _, ok := p.verbs[p.fuzzy]; p.fuzzy != 0 && !ok {
+		if err := add(p.fuzzy); err != nil {
+			return err
+		}
+	}
+	for _, d := range p.phrases {
+		if err := add(d.Start); err != nil {
+			return err
+		}
+		if d.End != d.Start {
+			if err := add(d.End); err != nil {
+				return err
+			}
+		}
+	}
+
+	// A default rune is disabled if it is already reserved, as in dialects
+	// where : is a separator.
+	yield := func(r *rune, def rune) {
+		if *r == def && p.IsReserved(def) {
+			*r = 0
+		}
+	}
+	yield(&p.field, FieldDelim)
+	yield(&p.boost, Caret)
+	for _, r := range []rune{p.field, p.boost} {
+		if r == 0 {
+			continue
+		}
+		if err := add(r); err != nil {
+			return err
+		}
+	}
+
+	// The regular expression delimiter, minimum should match rune and
+	// exact-match prefix are not reserved, since they only have a special
+	// meaning in certain positions.  Default ones yield to reserved runes
+	// like the above.
+	yield(&p.regex, Slash)
+	yield(&p.minShould, At)
+	yield(&p.exact, EqualsSign)
+	special := []rune{p.regex, p.minShould, p.exact}
+	for i, r := range special {
+		if p.IsReserved(r) || p.isWildcard(r) {
+			return fmt.Errorf("gossip: Rune %q has more than one role.", r)
+		}
+		for _, ri := range special[:i] {
+			if r != 0 && r == ri {
+				return fmt.Errorf("gossip: Rune %q has more than one role.", r)
+			}
+		}
+	}
+
+	// So are group prefixes, which only set the verbs Must and Not.  A
+	// default prefix is disabled if its rune has another role, as in
+	// dialects where & denotes Must.
+	for r, v := range p.prefixes {
+		taken := p.IsReserved(r) || p.isWildcard(r) || r == p.regex || r == p.minShould || r == p.exact
+		switch {
+		case taken && (r == Ampersand && v == Must || r == Exclamation && v == Not):
+			delete(p.prefixes, r)
+		case taken:
+			return fmt.Errorf("gossip: Rune %q has more than one role.", r)
+		case v != Must && v != Not:
+			return fmt.Errorf("gossip: Verb %d is not valid.", v)
+		}
+	}
+
+	if p.subVerb == 0 {
+		p.subVerb = p.defaultVerb
+	}
+	for _, v := range []Verb{p.defaultVerb, p.subVerb} {
+		if !v.IsValid() {
+			return fmt.Errorf("gossip: Verb %d is not valid.", v)
+		}
+	}
+	return nil
+}
+
+// implicitVerb returns the verb applied to unmarked clauses at the input
+// subquery depth, where the top level of the query has depth 0.
+func (p *Parser) implicitVerb(depth int) Verb {
+	if depth == 0 {
+		return p.defaultVerb
+	}
+	return p.subVerb
+}
+
+// operator returns the keyword operator that the bare term text spells,
+// or opNone if it is not a keyword or keywords are disabled.
+func (p *Parser) operator(text string) operator {
+	if p.keywords == nil || text == "" {
+		return opNone
+	}
+
+	equal := func(keyword string) bool {
+		if keyword == "" {
+			return false
+		}
+		if p.keywords.IgnoreCase {
+			return strings.EqualFold(text, keyword)
+		}
+		return text == keyword
+	}
+
+	switch {
+	case equal(p.keywords.And):
+		return opAnd
+	case equal(p.keywords.Or):
+		return opOr
+	case equal(p.keywords.Not):
+		return opNot
+	}
+
+	// Proximity keywords are followed by their slop.
+	i := strings.LastIndexByte(text, byte(NearSlop))
+	if i == -1 || !isDigits(text[i+1:]) {
+		return opNone
+	}
+	switch text = text[:i]; {
+	case equal(p.keywords.Near):
+		return opNear
+	case equal(p.keywords.ONear):
+		return opONear
+	}
+	return opNone
+}
+
+// isDigits states if the input is a non-empty run of ASCII digits.
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// isExists states if the field is a pseudo field of existence queries.
+func (p *Parser) isExists(field string) bool {
+	for _, name := range p.exists {
+		if name != "" && name == field {
+			return true
+		}
+	}
+	return false
+}
+
+// verbString returns the string representing the verb in the parser's
+// dialect.
+func (p *Parser) verbString(v Verb) string {
+	for r, vi := range p.verbs {
+		if vi == v {
+			return string(r)
+		}
+	}
+	return VerbErrorString
+}
+
+// WithVerbRune denotes the modal verb by the input rune instead of its
+// default rune.  A zero rune disables the verb, so that clauses cannot be
+// marked with it, as in dialects where # is literal.  The rune cannot
+// already denote another verb, unless that verb is denoted by another
+// rune or disabled by a later option, as when swapping + and -.
+func WithVerbRune(v Verb, r rune) Option {
+	return func(p *Parser) {
+		for ri, vi := range p.verbs {
+			if vi == v {
+				delete(p.verbs, ri)
+			}
+		}
+		delete(p.displaced, v)
+		if r == 0 {
+			return
+		}
+		if vi, ok := p.verbs[r]; ok {
+			if p.displaced == nil {
+				p.displaced = make(map[Verb]rune)
+			}
+			p.displaced[vi] = r
+		}
+		p.verbs[r] = v
+	}
+}
+
+// WithGroupPrefix denotes the group prefix that sets the implicit verb v
+// of the clauses of a subquery by the input rune instead of its default
+// rune.  The verb must be Must, whose default prefix is &, or Not, whose
+// default prefix is !.  A zero rune disables the prefix.
+func WithGroupPrefix(v Verb, r rune) Option {
+	return func(p *Parser) {
+		for ri, vi := range p.prefixes {
+			if vi == v {
+				delete(p.prefixes, ri)
+			}
+		}
+		if r != 0 {
+			p.prefixes[r] = v
+		}
+	}
+}
+
+// WithSeparators replaces the separator runes.  White space, such as
+// tabs, line breaks and non-breaking spaces, separates clauses regardless.
+func WithSeparators(rs ...rune) Option {
+	return func(p *Parser) {
+		p.separators = make(map[rune]struct{}, len(rs))
+		for _, r := range rs {
+			p.separators[r] = struct{}{}
+		}
+	}
+}
+
+// WithGroupDelims replaces the pairs of runes that start and end
+// subqueries.  A subquery must be closed by the end rune paired with its
+// start rune.  Formatted subqueries use the first pair.  For example,
+// WithGroupDelims(Parens) only accepts parentheses.
+func WithGroupDelims(delims ...Delims) Option {
+	return func(p *Parser) {
+		p.groups = append([]Delims(nil), delims...)
+	}
+}
+
+// WithPhraseDelims replaces the pairs of runes that start and end
+// phrase literals.
+func WithPhraseDelims(delims ...Delims) Option {
+	return func(p *Parser) {
+		p.phrases = append([]Delims(nil), delims...)
+	}
+}
+
+// WithSmartQuotes states whether the pairs of SmartQuotes also delimit
+// phrase literals, so that text pasted from word processors, such as
+// “data science”, is a phrase.  Each phrase must be closed by the rune
+// paired with its opening rune.  Pairs whose opening rune already starts
+// phrase literals are skipped.  Since the apostrophe is then reserved,
+// terms such as it's must be escaped or quoted.
+func WithSmartQuotes(ok bool) Option {
+	return func(p *Parser) {
+		p.smart = ok
+	}
+}
+
+// WithFieldDelim denotes the separator of field qualifiers, as in
+// title:golang, by the input rune instead of a colon.  A zero rune
+// disables field qualifiers, as does a colon reserved by another option,
+// such as WithSeparators(';', ':').
+func WithFieldDelim(r rune) Option {
+	return func(p *Parser) {
+		p.field = r
+	}
+}
+
+// WithFuzzyRune denotes fuzziness and slop suffixes, as in golang~2 and
+// "data science"~3, by the input rune instead of a tilde.  The rune can
+// also denote a verb, since a suffix directly follows the term or phrase
+// literal it modifies.  A zero rune disables the suffixes.
+func WithFuzzyRune(r rune) Option {
+	return func(p *Parser) {
+		p.fuzzy = r
+	}
+}
+
+// WithBoostRune denotes boost suffixes, as in golang^2, by the input
+// rune instead of a caret.  A zero rune disables boosts, as does a caret
+// reserved by another option.
+func WithBoostRune(r rune) Option {
+	return func(p *Parser) {
+		p.boost = r
+	}
+}
+
+// WithRegexDelim delimits regular expression literals, as in
+// /go(lang)?/, by the input rune instead of a slash.  The rune cannot
+// have another role, but is only special at the start of a clause, so
+// that and/or remains a term.  A zero rune disables regular expressions,
+// as does a slash reserved by another option, such as
+// WithPhraseDelims(Delims{'/', '/'}).
+func WithRegexDelim(r rune) Option {
+	return func(p *Parser) {
+		p.regex = r
+	}
+}
+
+// WithMinShouldRune denotes minimum should match suffixes, as in
+// [a b c d]@2 and [a b c d]@75%, by the input rune instead of an at sign.
+// Like the regular expression delimiter, the rune cannot have another
+// role, but is only special directly after a subquery, so that
+// ops@corp.io remains a term.  A zero rune disables the suffixes, as
+// does an at sign reserved by another option.
+func WithMinShouldRune(r rune) Option {
+	return func(p *Parser) {
+		p.minShould = r
+	}
+}
+
+// WithExactRune denotes exact-match prefixes, as in =Go and
+// ="Rob Pike", by the input rune instead of an equals sign.  Like the
+// regular expression delimiter, the rune cannot have another role, but
+// is only special directly before a term or phrase literal at the start
+// of a clause, so that a=b remains a term.  A zero rune disables the
+// prefix, as does an equals sign reserved by another option.
+func WithExactRune(r rune) Option {
+	return func(p *Parser) {
+		p.exact = r
+	}
+}
+
+// WithExistsFields replaces the pseudo fields of existence queries.
+// Formatted existence queries use the first name.  Without names,
+// has:abstract is an ordinary term qualified by the field has.
+func WithExistsFields(names ...string) Option {
+	return func(p *Parser) {
+		p.exists = append([]string(nil), names...)
+	}
+}
+
+// WithWildcards states whether the runes * and ? are wildcards in bare
+// terms.  They are by default, so that mach* is a prefix term and
+// colo?r a wildcard term.  Otherwise, they are ordinary runes.
+func WithWildcards(ok bool) Option {
+	return func(p *Parser) {
+		p.wildcards = ok
+	}
+}
+
+// WithLeadingWildcards states whether a bare term can begin with a
+// wildcard, as in *ing.  Such terms are expensive for most backends to
+// match, and are rejected by default.
+func WithLeadingWildcards(ok bool) Option {
+	return func(p *Parser) {
+		p.leading = ok
+	}
+}
+
+// WithClauseStartVerbs states whether verb runes only denote verbs at
+// the start of a clause, that is at the start of the query or of a
+// subquery, or after a separator.  Elsewhere they are literal, so that
+// e-mail, c++, 2024-01-01 and t:-5 are single terms.  A verb rune
+// directly following a term or phrase literal still begins a suffix,
+// as in golang~2, and one beginning a clause is still a verb, so that
+// -5 excludes the term 5.
+func WithClauseStartVerbs(ok bool) Option {
+	return func(p *Parser) {
+		p.clauseVerbs = ok
+	}
+}
+
+// WithRecognizers states whether URLs, email addresses and version-like
+// identifiers are recognized as single terms at the start of a clause,
+// even though they contain reserved runes.  For example,
+// https://example.com/a-b?x=1, ops+alerts@corp.io and foo-bar_v2.3 are
+// then leaves of kind KindURL, KindEmail and KindIdentifier.  A
+// recognized term extends to the next separator, subquery end, phrase
+// delimiter or boost.
+func WithRecognizers(ok bool) Option {
+	return func(p *Parser) {
+		p.recognize = ok
+	}
+}
+
+// WithDefaultVerb sets the verb applied to clauses without an explicit
+// verb.  By default this is Should, so that `x y z` is a disjunction.
+// With Must, the query is instead a conjunction.  The verb also applies
+// within subqueries, unless WithSubqueryDefaultVerb is given.
+func WithDefaultVerb(v Verb) Option {
+	return func(p *Parser) {
+		p.defaultVerb = v
+	}
+}
+
+// WithSubqueryDefaultVerb sets the verb applied to clauses of subqueries
+// without an explicit verb.  For example, with a default verb of Must and
+// a subquery default verb of Should, the query `go [generics iterators]`
+// must contain go and at least one of generics and iterators.
+func WithSubqueryDefaultVerb(v Verb) Option {
+	return func(p *Parser) {
+		p.subVerb = v
+	}
+}
+
+// WithKeywords enables the keyword operators, which are recognized as
+// bare terms outside of phrase literals.  For example, with
+// DefaultKeywords the query
+//
+//	golang AND [generics OR iterators] NOT java
+//
+// parses to the same tree as `+golang +[generics iterators] -java`.
+// NEAR/k and ONEAR/k join the clauses on either side into a near clause,
+// matching them within k words of each other, in order for ONEAR.  They
+// bind tightest, so `x breach NEAR/5 contract` has two clauses.
+// NOT binds tighter than AND, which binds tighter than OR.  Adjacent
+// clauses without an operator are joined by AND if the implicit verb is
+// Must, and by OR otherwise, except that NOT always joins by AND.
+func WithKeywords(k Keywords) Option {
+	return func(p *Parser) {
+		p.keywords = &k
+	}
+}
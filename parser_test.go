@@ -0,0 +1,629 @@
+package gossip
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewParserDefault(t *testing.T) {
+	p := NewParser()
+	for _, r := range []rune{
+		Space, Comma, Quote, Plus, Minus, Tilde, Hash, LeftBracket, RightBracket,
+		LeftParen, RightParen, Colon, Caret, Escape,
+	} {
+		assert.True(t, p.IsReserved(r), string(r))
+	}
+	assert.False(t, p.IsReserved('a'))
+	assert.False(t, p.IsReserved(utf8.RuneError))
+
+	// Default runes yield to the roles that options reserve.
+	tests := []struct {
+		opt Option
+		in  string
+		out string
+	}{
+		{WithVerbRune(Must, ':'), `:a b`, `~[+"a", ~"b"]`},
+		{WithSeparators(Space, ';', ':'), `a:b;c`, `~[~"a", ~"b", ~"c"]`},
+		{WithVerbRune(Must, '^'), `^a b`, `~[+"a", ~"b"]`},
+		{WithVerbRune(Must, '/'), `/a b`, `~[+"a", ~"b"]`},
+		{WithPhraseDelims(Delims{'/', '/'}), `/a b/ c`, `~[~"a b", ~"c"]`},
+		{WithVerbRune(Must, '@'), `@a [b c]`, `~[+"a", ~[~"b", ~"c"]]`},
+		{WithVerbRune(Must, '='), `=a b`, `~[+"a", ~"b"]`},
+	}
+
+	for i, tt := range tests {
+		msg := fmt.Sprintf("Fails test case (%d) %s", i, tt.in)
+		tree, err := NewParser(tt.opt).Parse(tt.in)
+		if assert.NoError(t, err, msg) {
+			assert.Equal(t, tt.out, tree.String(), msg)
+		}
+	}
+}
+
+func TestNewParserPanics(t *testing.T) {
+	tests := []Option{
+		WithSeparators(Plus),
+		WithVerbRune(Must, Space),
+		WithGroupDelims(Delims{LeftBracket, LeftBracket}),
+		WithPhraseDelims(Delims{Quote, Quote}, Delims{'\'', Quote}),
+		WithVerbRune(VerbError, '!'),
+		WithDefaultVerb(VerbError),
+		WithSeparators(Escape),
+		WithFuzzyRune(Comma),
+		WithFieldDelim(Quote),
+		WithBoostRune(Colon),
+		WithRegexDelim(Plus),
+		WithVerbRune(Not, '\t'),
+		WithFieldDelim('\u00a0'),
+		WithMinShouldRune(Comma),
+		WithRegexDelim(At),
+		WithRegexDelim(Asterisk),
+		WithGroupPrefix(Must, Plus),
+		WithGroupPrefix(Not, At),
+		WithGroupPrefix(Should, '%'),
+		WithExactRune(Quote),
+		WithExactRune(Slash),
+		WithGroupPrefix(Not, EqualsSign),
+		WithVerbRune(Should, Hash),
+		WithVerbRune(Not, Plus),
+	}
+
+	for i, opt := range tests {
+		msg := fmt.Sprintf("Fails test case (%d)", i)
+		assert.Panics(t, func() { NewParser(opt) }, msg)
+	}
+}
+
+func TestParserDialect(t *testing.T) {
+	p := NewParser(
+		WithVerbRune(Must, '&'),
+		WithVerbRune(Should, '|'),
+		WithVerbRune(Not, '!'),
+		WithSeparators(';', Space),
+		WithGroupDelims(Delims{'(', ')'}),
+		WithPhraseDelims(Delims{'\'', '\''}, Delims{'«', '»'}),
+	)
+
+	assert.True(t, p.IsRuneVerb('&'))
+	assert.False(t, p.IsRuneVerb(Plus))
+	assert.False(t, p.IsReserved(Plus))
+	assert.True(t, p.IsSeparator(';'))
+	assert.False(t, p.IsSeparator(Comma))
+	assert.True(t, p.IsSeparator('\n'))
+	assert.True(t, p.IsSubqueryStart('('))
+	assert.True(t, p.IsSubqueryEnd(')'))
+	assert.False(t, p.IsSubqueryStart(LeftBracket))
+	assert.True(t, p.IsPhraseDelim('»'))
+	assert.False(t, p.IsPhraseDelim(Quote))
+	assert.True(t, p.IsPairValid(Space, '&'))
+	assert.False(t, p.IsPairValid('&', '!'))
+	assert.True(t, p.IsTripleValid(';', '!', 'a'))
+	r, i := p.NextReserved("c++;x")
+	assert.Equal(t, ';', r)
+	assert.Equal(t, 3, i)
+
+	tests := []struct {
+		in  string
+		out string
+	}{
+		{"c++", `~"c++"`},
+		{"a;&b !c", `~[~"a", +"b", -"c"]`},
+		{`&'data science' !(x;|y)`, `~[+"data science", -[~"x", ~"y"]]`},
+		{`«a 'b» [c]`, `~[~"a 'b", ~"[c]"]`},
+		{`'it\'s'`, `~"it's"`},
+	}
+
+	for i, tt := range tests {
+		msg := fmt.Sprintf("Fails test case (%d) %s", i, tt.in)
+		tree, err := p.Parse(tt.in)
+		assert.NoError(t, err, msg)
+		assert.Equal(t, tt.out, tree.String(), msg)
+	}
+
+	failures := []string{"&&x", "(x", "x)", "'x", "x»", "a,b (&)"}
+	for i, tt := range failures {
+		msg := fmt.Sprintf("Fails test case (%d) %s", i, tt)
+		_, err := p.Parse(tt)
+		assert.Error(t, err, msg)
+	}
+}
+
+func TestParserFilterVerb(t *testing.T) {
+	tree, err := Parse("#x")
+	assert.NoError(t, err)
+	assert.Equal(t, Filter, tree.Verb)
+	_, err = Parse("c#")
+	assert.True(t, errors.Is(err, ErrVerbSequence))
+
+	// Without a rune for the verb, # is literal.
+	p := NewParser(WithVerbRune(Filter, 0))
+	assert.False(t, p.IsRuneVerb(Hash))
+	tree, err = p.Parse("c# #x")
+	assert.NoError(t, err)
+	assert.Equal(t, `~[~"c#", ~"#x"]`, tree.String())
+
+	p = NewParser(WithVerbRune(Filter, '!'))
+	tree, err = p.Parse("!x")
+	assert.NoError(t, err)
+	assert.Equal(t, Filter, tree.Verb)
+	assert.Equal(t, "!x", p.Format(tree))
+
+	// A verb rune can be taken from a verb that is then denoted by
+	// another rune or disabled.
+	p = NewParser(WithVerbRune(Should, Hash), WithVerbRune(Filter, 0))
+	tree, err = p.Parse("#x")
+	assert.NoError(t, err)
+	assert.Equal(t, Should, tree.Verb)
+	p = NewParser(WithVerbRune(Must, Minus), WithVerbRune(Not, Plus))
+	tree, err = p.Parse("-x +y")
+	assert.NoError(t, err)
+	assert.Equal(t, `~[+"x", -"y"]`, tree.String())
+}
+
+func TestParserDefaultVerb(t *testing.T) {
+	p := NewParser(WithDefaultVerb(Must))
+	tree, err := p.Parse("x ~y -[z w]")
+	assert.NoError(t, err)
+	assert.Equal(t, `~[+"x", ~"y", -[+"z", +"w"]]`, tree.String())
+
+	p = NewParser(WithDefaultVerb(Must), WithSubqueryDefaultVerb(Should))
+	tree, err = p.Parse("x -[z w] [[v]]")
+	assert.NoError(t, err)
+	assert.Equal(t, `~[+"x", -[~"z", ~"w"], +[~[~"v"]]]`, tree.String())
+
+	assert.Panics(t, func() { NewParser(WithSubqueryDefaultVerb(VerbError)) })
+}
+
+func TestParserLenient(t *testing.T) {
+	p := NewParser(WithGroupDelims(Delims{'(', ')'}))
+	tree, diags := p.ParseLenient("x (y")
+	assert.Equal(t, `~[~"x", ~[~"y"]]`, tree.String())
+	if assert.Len(t, diags, 1) {
+		assert.True(t, errors.Is(diags[0], ErrUnpairedBracket))
+	}
+}
+
+func TestParserLex(t *testing.T) {
+	p := NewParser(WithGroupDelims(Delims{'(', ')'}))
+	tokens, err := p.Lex("(x)")
+	assert.NoError(t, err)
+	kinds := make([]TokenKind, len(tokens))
+	for i, tok := range tokens {
+		kinds[i] = tok.Kind
+	}
+	assert.Equal(t, []TokenKind{TokenGroupStart, TokenTerm, TokenGroupEnd, TokenEOF}, kinds)
+}
+
+func TestParserKeywords(t *testing.T) {
+	p := NewParser(WithKeywords(DefaultKeywords))
+	and := NewParser(WithKeywords(DefaultKeywords), WithDefaultVerb(Must))
+	fold := NewParser(WithKeywords(Keywords{And: "and", Or: "or", IgnoreCase: true}))
+
+	tests := []struct {
+		p    *Parser
+		in   string
+		same string // query without keywords that parses to the same tree
+	}{
+		{p, "golang AND [generics OR iterators] NOT java", "+golang +[generics iterators] -java"},
+		{p, "a OR b", "a b"},
+		{p, "a AND b", "+a +b"},
+		{p, "NOT a", "-a"},
+		{p, "a NOT b", "+a -b"},
+		{p, "a OR NOT b", "a -b"},
+		{p, "a b AND c", "a [+b +c]"},
+		{p, "a AND b OR c AND d", "[+a +b] [+c +d]"},
+		{p, "a AND b OR c", "[+a +b] c"},
+		{p, "+a AND ~b", "+a ~b"},
+		{p, "[a AND b] c", "[+a +b] c"},
+		{p, `"AND" \AND and`, `"AND" "AND" and`},
+		{and, "a b OR c", "[+a +b] ~c"},
+		{and, "a OR b c", "~a ~[+b +c]"},
+		{fold, "a AND b Or c", "[+a +b] c"},
+		{fold, "a not b", "a not b"},
+	}
+
+	for i, tt := range tests {
+		msg := fmt.Sprintf("Fails test case (%d) %s", i, tt.in)
+		tree, err := tt.p.Parse(tt.in)
+		assert.NoError(t, err, msg)
+		same, err := Parse(tt.same)
+		assert.NoError(t, err, msg)
+		assert.Equal(t, same.String(), tree.String(), msg)
+	}
+
+	failures := []struct {
+		in     string
+		err    error
+		offset int
+	}{
+		{"a AND", ErrOperatorSequence, 2},
+		{"AND a", ErrOperatorSequence, 0},
+		{"a AND OR b", ErrOperatorSequence, 6},
+		{"a NOT", ErrOperatorSequence, 2},
+		{"NOT NOT a", ErrOperatorSequence, 4},
+		{"[a OR] b", ErrOperatorSequence, 3},
+		{"[OR a]", ErrOperatorSequence, 1},
+		{"+AND a", ErrOperatorSequence, 1},
+		{"NOT +a", ErrVerbSequence, 4},
+	}
+
+	for i, tt := range failures {
+		msg := fmt.Sprintf("Fails test case (%d) %s", i, tt.in)
+		_, err := p.Parse(tt.in)
+		assert.True(t, errors.Is(err, tt.err), msg)
+		var perr *ParseError
+		if assert.True(t, errors.As(err, &perr), msg) {
+			assert.Equal(t, tt.offset, perr.Offset, msg)
+		}
+	}
+}
+
+func TestParserKeywordsSpans(t *testing.T) {
+	p := NewParser(WithKeywords(DefaultKeywords))
+	tree, err := p.Parse("a AND b OR c")
+	assert.NoError(t, err)
+	start, end := tree.Children[0].Span()
+	assert.Equal(t, 0, start)
+	assert.Equal(t, 7, end)
+}
+
+func TestParserKeywordsLenient(t *testing.T) {
+	p := NewParser(WithKeywords(DefaultKeywords))
+	tree, diags := p.ParseLenient("a AND OR b NOT")
+	assert.Equal(t, `~[+"a", +"b"]`, tree.String())
+	if assert.Len(t, diags, 2) {
+		assert.Equal(t, 6, diags[0].Offset)
+		assert.Equal(t, 11, diags[1].Offset)
+		assert.Equal(t, RepairDropped, diags[1].Repair)
+	}
+}
+
+func TestParserNear(t *testing.T) {
+	p := NewParser(WithKeywords(DefaultKeywords))
+
+	tests := []struct {
+		in  string
+		out string
+	}{
+		{`"breach" NEAR/5 "contract"`, `~[~NEAR/5["breach", "contract"]]`},
+		{`x -a ONEAR/3 b^2 y`, `~[~"x", -ONEAR/3["a", "b"^2], ~"y"]`},
+		{`a NEAR/1 [b c] NEAR/2 d`, `~[~NEAR/2[NEAR/1["a", [~"b", ~"c"]], "d"]]`},
+		{`a AND b NEAR/1 c OR d`, `~[~[+"a", +NEAR/1["b", "c"]], ~"d"]`},
+		{`NOT a NEAR/0 b`, `~[-NEAR/0["a", "b"]]`},
+		{`t:a near/3 b`, `~[~t:"a", ~"near/3", ~"b"]`},
+	}
+
+	for i, tt := range tests {
+		msg := fmt.Sprintf("Fails test case (%d) %s", i, tt.in)
+		tree, err := p.Parse(tt.in)
+		if assert.NoError(t, err, msg) {
+			assert.Equal(t, tt.out, tree.String(), msg)
+			assert.True(t, tree.IsTreeValid(), msg)
+		}
+	}
+
+	// The canonical form of near clauses is not read as terms without
+	// keywords.
+	tree, err := p.Parse(`a NEAR/3 b`)
+	if assert.NoError(t, err) {
+		_, err = Parse(tree.String())
+		assert.True(t, errors.Is(err, ErrUnexpectedReservedRune))
+	}
+
+	tree, err = p.Parse(`a ONEAR/2 b`)
+	assert.NoError(t, err)
+	near := tree.Children[0]
+	assert.Equal(t, KindNear, near.Kind)
+	assert.Equal(t, 2, near.Slop)
+	assert.True(t, near.Ordered)
+	assert.Equal(t, Must, near.Children[0].Verb)
+	assert.Equal(t, Must, near.Children[1].Verb)
+
+	// Near clauses span their operands, including later suffixes.
+	tree, err = p.Parse(`x a NEAR/1 [b]^2 NEAR/3 c~1`)
+	assert.NoError(t, err)
+	near = tree.Children[1]
+	assert.Equal(t, []int{2, 27}, []int{near.Start, near.End})
+	assert.Equal(t, []int{2, 16}, []int{near.Children[0].Start, near.Children[0].End})
+
+	failures := []struct {
+		in     string
+		err    error
+		offset int
+	}{
+		{"NEAR/3 a", ErrOperatorSequence, 0},
+		{"a NEAR/3", ErrOperatorSequence, 2},
+		{"a NEAR/3 -b", ErrVerbSequence, 9},
+		{"a NEAR/3 NOT b", ErrOperatorSequence, 9},
+		{"a AND NEAR/3 b", ErrOperatorSequence, 6},
+		{"a NEAR/3 OR b", ErrOperatorSequence, 9},
+		{"[a NEAR/3] b", ErrOperatorSequence, 3},
+		{"a NEAR/99999999999999999999 b", ErrMalformedModifier, 2},
+	}
+
+	for i, tt := range failures {
+		msg := fmt.Sprintf("Fails test case (%d) %s", i, tt.in)
+		_, err := p.Parse(tt.in)
+		assert.True(t, errors.Is(err, tt.err), msg)
+		var perr *ParseError
+		if assert.True(t, errors.As(err, &perr), msg) {
+			assert.Equal(t, tt.offset, perr.Offset, msg)
+		}
+	}
+
+	// Lenient parsers drop verbs on operands, and replace a near clause
+	// missing its right operand by its left.
+	tree, diags := p.ParseLenient(`a NEAR/3 -b`)
+	assert.Equal(t, `~[~NEAR/3["a", "b"]]`, tree.String())
+	assert.Len(t, diags, 1)
+	tree, diags = p.ParseLenient(`x -a NEAR/3 [] y`)
+	assert.Equal(t, `~[~"x", -"a", ~"y"]`, tree.String())
+	assert.Len(t, diags, 1)
+
+	// They drop proximity operators whose slop overflows.
+	tree, diags = p.ParseLenient(`a NEAR/99999999999999999999 b`)
+	assert.Equal(t, `~[~"a", ~"b"]`, tree.String())
+	assert.Len(t, diags, 1)
+}
+
+func TestParserClauseStartVerbs(t *testing.T) {
+	p := NewParser(WithClauseStartVerbs(true))
+
+	tests := []struct {
+		in  string
+		out string
+	}{
+		{`e-mail`, `~"e-mail"`},
+		{`c++ -java`, `~[~"c++", -"java"]`},
+		{`2024-01-01 t:-5`, `~[~"2024-01-01", ~t:"-5"]`},
+		{`x -5`, `~[~"x", -"5"]`},
+		{`[-a b+]`, `~[~[-"a", ~"b+"]]`},
+		{`go~2 a~b e-mail^2`, `~[~go~2, ~"a~b", ~"e-mail"^2]`},
+		{`a-*`, `~a\-*`},
+		{`x:>-5`, `~x:>-5`},
+	}
+
+	for i, tt := range tests {
+		msg := fmt.Sprintf("Fails test case (%d) %s", i, tt.in)
+		tree, err := p.Parse(tt.in)
+		if assert.NoError(t, err, msg) {
+			assert.Equal(t, tt.out, tree.String(), msg)
+
+			// Formatted queries parse to the same tree.
+			again, err := p.Parse(p.Format(tree))
+			assert.NoError(t, err, msg)
+			assert.True(t, tree.Equals(again), msg)
+		}
+	}
+
+	// Verbs beginning a clause must still be followed by it.
+	failures := []struct {
+		in     string
+		offset int
+	}{
+		{"a + b", 2},
+		{"a +", 2},
+		{"++a", 0},
+		{"[a +]", 3},
+	}
+
+	for i, tt := range failures {
+		msg := fmt.Sprintf("Fails test case (%d) %s", i, tt.in)
+		_, err := p.Parse(tt.in)
+		assert.True(t, errors.Is(err, ErrVerbSequence), msg)
+		var perr *ParseError
+		if assert.True(t, errors.As(err, &perr), msg) {
+			assert.Equal(t, tt.offset, perr.Offset, msg)
+		}
+	}
+
+	// A verb rune ending a term is literal, and so does not begin a
+	// subquery or phrase after it, as in e[x].  Lenient parsers agree.
+	reserved := []struct {
+		in     string
+		offset int
+	}{
+		{"e[x]", 1},
+		{"e-[x]", 2},
+		{"e-(x)", 2},
+		{"0#[0]", 2},
+		{`a-"b"`, 2},
+		{`}#"x:>=1a="`, 2},
+	}
+
+	for i, tt := range reserved {
+		msg := fmt.Sprintf("Fails test case (%d) %s", i, tt.in)
+		_, err := p.Parse(tt.in)
+		assert.True(t, errors.Is(err, ErrUnexpectedReservedRune), msg)
+		var perr *ParseError
+		if assert.True(t, errors.As(err, &perr), msg) {
+			assert.Equal(t, tt.offset, perr.Offset, msg)
+		}
+		_, diags := p.ParseLenient(tt.in)
+		if assert.NotEmpty(t, diags, msg) {
+			assert.True(t, errors.Is(diags[0], ErrUnexpectedReservedRune), msg)
+			assert.Equal(t, tt.offset, diags[0].Offset, msg)
+		}
+	}
+
+	// Verb runes split terms in the default dialect.
+	_, err := Parse("e-mail")
+	assert.Error(t, err)
+}
+
+func TestParserRecognizers(t *testing.T) {
+	p := NewParser(WithRecognizers(true))
+	tree, err := p.Parse(`https://example.com/a-b?x=1 -ops+alerts@corp.io site:[foo-bar_v2.3^2 go] title:go`)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	leaves := []*Node{tree.Children[0], tree.Children[1], tree.Children[2].Children[0], tree.Children[3]}
+	kinds := make([]NodeKind, len(leaves))
+	phrases := make([]string, len(leaves))
+	for i, leaf := range leaves {
+		kinds[i] = leaf.Kind
+		phrases[i] = leaf.Phrase
+	}
+	assert.Equal(t, []NodeKind{KindURL, KindEmail, KindIdentifier, KindText}, kinds)
+	assert.Equal(t, []string{"https://example.com/a-b?x=1", "ops+alerts@corp.io", "foo-bar_v2.3", "go"}, phrases)
+	assert.Equal(t, Not, leaves[1].Verb)
+	assert.Equal(t, 2.0, leaves[2].Boost)
+	assert.Equal(t, "site", leaves[2].GetField())
+
+	out := p.Format(tree)
+	assert.Equal(t, `https://example.com/a-b?x=1 -ops+alerts@corp.io site:[foo-bar_v2.3^2 go] title:go`, out)
+	again, err := p.Parse(out)
+	assert.NoError(t, err)
+	assert.True(t, tree.Equals(again))
+
+	// Recognized terms end at separators, and are otherwise ordinary.
+	tree, err = p.Parse("https://x.com,y")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://x.com", tree.Children[0].Phrase)
+	_, err = p.Parse("e-mail")
+	assert.Error(t, err)
+
+	// Other dialects cannot express recognized terms.
+	assert.Equal(t, "", defaultParser.Format(tree))
+	_, err = Parse("ops+alerts@corp.io")
+	assert.Error(t, err)
+}
+
+func TestParserGroupPrefix(t *testing.T) {
+	p := NewParser(WithGroupPrefix(Must, '%'), WithGroupPrefix(Not, 0))
+	tree, err := p.Parse("%[a b] & [c] ! [d]")
+	assert.NoError(t, err)
+	assert.Equal(t, `~[~&[+"a", +"b"], ~"&", ~[~"c"], ~"!", ~[~"d"]]`, tree.String())
+	assert.Equal(t, `%[a b] & [c] ! [d]`, p.Format(tree))
+
+	// Default prefixes yield to other roles of their runes.
+	p = NewParser(WithVerbRune(Must, '&'))
+	tree, err = p.Parse("&[a b]")
+	assert.NoError(t, err)
+	assert.Equal(t, `~[+[~"a", ~"b"]]`, tree.String())
+}
+
+func TestParserExactRune(t *testing.T) {
+	p := NewParser(WithExactRune('%'))
+	tree, err := p.Parse(`%Go =Go`)
+	assert.NoError(t, err)
+	assert.Equal(t, `~[~="Go", ~"=Go"]`, tree.String())
+	assert.Equal(t, `%Go =Go`, p.Format(tree))
+
+	p = NewParser(WithExactRune(0))
+	tree, err = p.Parse("=Go")
+	assert.NoError(t, err)
+	assert.False(t, tree.Exact)
+	assert.Equal(t, "=Go", tree.Phrase)
+}
+
+func TestParserFuzzyRune(t *testing.T) {
+	p := NewParser(WithFuzzyRune('%'))
+	assert.True(t, p.IsReserved('%'))
+	tree, err := p.Parse(`go%1 "a b"%2 ~c`)
+	assert.NoError(t, err)
+	assert.Equal(t, `~[~go~1, ~"a b"~2, ~"c"]`, tree.String())
+	assert.Equal(t, `go%1 "a b"%2 c`, p.Format(tree))
+
+	_, err = p.Parse("go~1")
+	assert.Error(t, err)
+
+	p = NewParser(WithFuzzyRune(0))
+	_, err = p.Parse("go~1")
+	assert.Error(t, err)
+	assert.Equal(t, "", p.Format(NewNode().SetPhrase("go").SetFuzziness(1)))
+}
+
+func TestParserBoostRune(t *testing.T) {
+	p := NewParser(WithBoostRune('!'))
+	tree, err := p.Parse("go!2 x^y")
+	assert.NoError(t, err)
+	assert.Equal(t, `~[~"go"^2, ~"x^y"]`, tree.String())
+	assert.Equal(t, "go!2 x^y", p.Format(tree))
+
+	p = NewParser(WithBoostRune(0))
+	_, err = p.Parse("[a]^2")
+	assert.Error(t, err)
+	assert.Equal(t, "", p.Format(NewNode().SetPhrase("go").SetBoost(2)))
+}
+
+func TestParserRegexDelim(t *testing.T) {
+	p := NewParser(WithVerbRune(Filter, 0), WithRegexDelim('#'))
+	tree, err := p.Parse(`#a/b# /c/`)
+	assert.NoError(t, err)
+	assert.Equal(t, KindRegex, tree.Children[0].Kind)
+	assert.Equal(t, "a/b", tree.Children[0].Phrase)
+	assert.Equal(t, `/c/`, tree.Children[1].Phrase)
+	assert.Equal(t, `#a/b# /c/`, p.Format(tree))
+
+	p = NewParser(WithRegexDelim(0))
+	tree, err = p.Parse("/c/")
+	assert.NoError(t, err)
+	assert.Equal(t, KindText, tree.Kind)
+	assert.Equal(t, "", p.Format(NewNode().SetPhrase("c").SetKind(KindRegex)))
+}
+
+func TestParserSmartQuotes(t *testing.T) {
+	p := NewParser(WithSmartQuotes(true))
+	tree, err := p.Parse("“data science” +‘go’ 'rust' «x y» \"z\"")
+	assert.NoError(t, err)
+	assert.Equal(t, `~[~"data science", +"go", ~"rust", ~"x y", ~"z"]`, tree.String())
+	assert.Equal(t, `"data science" +go rust "x y" z`, p.Format(tree))
+
+	// Phrases must be closed by the rune paired with the opening rune.
+	for _, in := range []string{"“a\"", "‘a'", "«a”", "it's"} {
+		_, err = p.Parse(in)
+		assert.Error(t, err, in)
+	}
+	_, err = p.Parse("“a\" b")
+	var perr *ParseError
+	if assert.True(t, errors.As(err, &perr)) {
+		assert.True(t, errors.Is(err, ErrUnpairedQuotation))
+		assert.Equal(t, []string{"closing ”"}, perr.Expected)
+	}
+
+	// Curly quotes are ordinary runes by default.
+	tree, err = Parse("“a”")
+	assert.NoError(t, err)
+	assert.Equal(t, "“a”", tree.Phrase)
+
+	// Pairs whose opening rune already starts phrases are skipped.
+	p = NewParser(WithPhraseDelims(Delims{'«', '»'}, Delims{'\'', '"'}), WithSmartQuotes(true))
+	tree, err = p.Parse(`'a" “b”`)
+	assert.NoError(t, err)
+	assert.Equal(t, `~[~"a", ~"b"]`, tree.String())
+}
+
+func TestParserExistsFields(t *testing.T) {
+	p := NewParser(WithExistsFields("exists"))
+	tree, err := p.Parse("exists:author has:x")
+	assert.NoError(t, err)
+	assert.Equal(t, `~[~has:author, ~has:"x"]`, tree.String())
+	assert.Equal(t, KindExists, tree.Children[0].Kind)
+	assert.Equal(t, KindText, tree.Children[1].Kind)
+	assert.Equal(t, "exists:author has:x", p.Format(tree))
+
+	p = NewParser(WithExistsFields())
+	tree, err = p.Parse("has:author")
+	assert.NoError(t, err)
+	assert.Equal(t, KindText, tree.Kind)
+	assert.Equal(t, "", p.Format(NewNode().SetExists("author")))
+}
+
+func TestParserMinShouldRune(t *testing.T) {
+	p := NewParser(WithMinShouldRune('%'))
+	tree, err := p.Parse("[a b]%1 c@d")
+	assert.NoError(t, err)
+	assert.Equal(t, `~[~[~"a", ~"b"]@1, ~"c@d"]`, tree.String())
+	assert.Equal(t, "[a b]%1 c@d", p.Format(tree))
+
+	p = NewParser(WithMinShouldRune(0))
+	_, err = p.Parse("[a b]@1")
+	assert.Error(t, err)
+	assert.Equal(t, "", p.Format(tree))
+}
@@ -0,0 +1,282 @@
+package gossip
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Runes and keywords of range queries.
+const (
+	RangeInclusiveStart rune   = LeftBracket
+	RangeInclusiveEnd   rune   = RightBracket
+	RangeExclusiveStart rune   = 0x0000007b // {
+	RangeExclusiveEnd   rune   = 0x0000007d // }
+	RangeTo             string = "TO"
+	RangeOpen           string = "*"
+)
+
+// DateLayouts are the layouts of date bounds, in the order they are tried.
+var DateLayouts = []string{"2006-01-02", time.RFC3339}
+
+// RangeType identifies the type of the bounds of a range query.
+type RangeType int
+
+// Range types.
+const (
+	RangeNumber RangeType = iota + 1 // Bounds are numbers, such as 10 or -2.5.
+	RangeDate                        // Bounds are dates, such as 2024-01-01.
+)
+
+var rangeTypeStrings = map[RangeType]string{
+	RangeNumber: "number",
+	RangeDate:   "date",
+}
+
+func (t RangeType) String() string {
+	if ts, ok := rangeTypeStrings[t]; ok {
+		return ts
+	}
+	return "_error"
+}
+
+// Bound is an end of the interval matched by a range query.  Only the
+// value corresponding to the type of the range is set.
+type Bound struct {
+	Text      string    `json:"text"`                // Source text of the bound.
+	Inclusive bool      `json:"inclusive,omitempty"` // Whether the interval contains the bound.
+	Number    float64   `json:"-"`                   // Value of a number bound.
+	Time      time.Time `json:"-"`                   // Value of a date bound.
+}
+
+// Range is the interval of values matched by a range query, such as
+// year:[2010 TO 2020] or price:>=10.  A nil bound means the interval is
+// unbounded on that side.
+type Range struct {
+	Type  RangeType `json:"type"`
+	Lower *Bound    `json:"lower,omitempty"`
+	Upper *Bound    `json:"upper,omitempty"`
+}
+
+// newRange parses the text of a range query following a field qualifier.
+// Ranges are written as an interval, such as [2010 TO 2020] or
+// {2010 TO *], where brackets include and braces exclude the bound and *
+// is unbounded, or as a comparison, such as >=10 or <2024-01-01.  If the
+// range is malformed, newRange also returns the byte offset of the
+// problem in the text.
+func newRange(s string) (*Range, int, error) {
+	r := &Range{}
+
+	// A comparison has a single bound.
+	var i int
+	switch {
+	case strings.HasPrefix(s, ">="), strings.HasPrefix(s, "<="):
+		i = 2
+	case strings.HasPrefix(s, ">"), strings.HasPrefix(s, "<"):
+		i = 1
+	}
+	if i > 0 {
+		b := &Bound{Text: s[i:], Inclusive: s[i-1] == '='}
+		if s[0] == '>' {
+			r.Lower = b
+		} else {
+			r.Upper = b
+		}
+		if b.Text == RangeOpen {
+			return nil, i, ErrMalformedRange
+		}
+		if _, err := r.parseBounds(); err != nil {
+			return nil, i, err
+		}
+		return r, 0, nil
+	}
+
+	// An interval is delimited by brackets or braces.
+	start, w := utf8.DecodeRuneInString(s)
+	end, v := utf8.DecodeLastRuneInString(s)
+	switch {
+	case start != RangeInclusiveStart && start != RangeExclusiveStart:
+		return nil, 0, ErrMalformedRange
+	case len(s) < w+v || end != RangeInclusiveEnd && end != RangeExclusiveEnd:
+		return nil, len(s), ErrMalformedRange
+	}
+
+	// The bounds are separated by TO.
+	words, offsets := fields(s[w : len(s)-v])
+	if len(words) != 3 || words[1] != RangeTo {
+		return nil, w, ErrMalformedRange
+	}
+	if words[0] != RangeOpen {
+		r.Lower = &Bound{Text: words[0], Inclusive: start == RangeInclusiveStart}
+	}
+	if words[2] != RangeOpen {
+		r.Upper = &Bound{Text: words[2], Inclusive: end == RangeInclusiveEnd}
+	}
+	if r.Lower == nil && r.Upper == nil {
+		return nil, w, ErrMalformedRange
+	}
+
+	b, err := r.parseBounds()
+	switch {
+	case err == nil:
+		return r, 0, nil
+	case b == r.Lower:
+		return nil, w + offsets[0], err
+	}
+	return nil, w + offsets[2], err
+}
+
+// fields splits s around runs of white space like strings.Fields, and
+// also returns the byte offset of each field.
+func fields(s string) ([]string, []int) {
+	var (
+		words   []string
+		offsets []int
+		start   = -1
+	)
+	for i, r := range s + " " {
+		switch {
+		case !unicode.IsSpace(r) && start == -1:
+			start = i
+		case unicode.IsSpace(r) && start != -1:
+			words = append(words, s[start:i])
+			offsets = append(offsets, start)
+			start = -1
+		}
+	}
+	return words, offsets
+}
+
+// parseBounds sets the type of the range and the values of its bounds
+// from their text.  Both bounds must have the same type, and the lower
+// bound cannot exceed the upper bound.  If the bounds are malformed,
+// parseBounds also returns the offending bound.
+func (r *Range) parseBounds() (*Bound, error) {
+	r.Type = 0
+	for _, b := range []*Bound{r.Lower, r.Upper} {
+		if b == nil {
+			continue
+		}
+		t, err := b.parse()
+		if err != nil || r.Type != 0 && t != r.Type {
+			return b, ErrMalformedRange
+		}
+		r.Type = t
+	}
+
+	if r.Lower != nil && r.Upper != nil {
+		var reversed bool
+		switch r.Type {
+		case RangeNumber:
+			reversed = r.Lower.Number > r.Upper.Number
+		case RangeDate:
+			reversed = r.Lower.Time.After(r.Upper.Time)
+		}
+		if reversed {
+			return r.Upper, ErrMalformedRange
+		}
+	}
+	return nil, nil
+}
+
+// parse sets the value of the bound from its text, and reports its type.
+func (b *Bound) parse() (RangeType, error) {
+	if f, err := strconv.ParseFloat(b.Text, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+		b.Number = f
+		return RangeNumber, nil
+	}
+	for _, layout := range DateLayouts {
+		if t, err := time.Parse(layout, b.Text); err == nil {
+			b.Time = t
+			return RangeDate, nil
+		}
+	}
+	return 0, ErrMalformedRange
+}
+
+// IsValid reports whether the range has at least one bound, and the
+// bounds are well formed values of the type of the range.
+func (r *Range) IsValid() bool {
+	if r == nil {
+		return false
+	}
+	c := Range{}
+	if r.Lower != nil {
+		c.Lower = &Bound{Text: r.Lower.Text}
+	}
+	if r.Upper != nil {
+		c.Upper = &Bound{Text: r.Upper.Text}
+	}
+	_, err := c.parseBounds()
+	return err == nil && c.Type != 0 && c.Type == r.Type
+}
+
+// Equals reports whether the ranges have the same type and bounds.
+func (r *Range) Equals(s *Range) bool {
+	if r == nil || s == nil {
+		return r == s
+	}
+	equal := func(a, b *Bound) bool {
+		if a == nil || b == nil {
+			return a == b
+		}
+		return a.Text == b.Text && a.Inclusive == b.Inclusive
+	}
+	return r.Type == s.Type && equal(r.Lower, s.Lower) && equal(r.Upper, s.Upper)
+}
+
+// String formats the range as it follows a field qualifier.  Ranges
+// with a single bound are formatted as comparisons, such as >=10.
+func (r *Range) String() string {
+	if r == nil {
+		return ""
+	}
+
+	switch {
+	case r.Lower != nil && r.Upper != nil:
+	case r.Lower != nil:
+		return r.Lower.comparison(">")
+	case r.Upper != nil:
+		return r.Upper.comparison("<")
+	default:
+		return ""
+	}
+
+	start, end := RangeExclusiveStart, RangeExclusiveEnd
+	if r.Lower.Inclusive {
+		start = RangeInclusiveStart
+	}
+	if r.Upper.Inclusive {
+		end = RangeInclusiveEnd
+	}
+	return string(start) + r.Lower.Text + " " + RangeTo + " " + r.Upper.Text + string(end)
+}
+
+// comparison formats a single bound with the input operator.
+func (b *Bound) comparison(op string) string {
+	if b.Inclusive {
+		op += "="
+	}
+	return op + b.Text
+}
+
+// UnmarshalJSON decodes a range and sets the values of its bounds from
+// their text.
+func (r *Range) UnmarshalJSON(data []byte) error {
+	type plain Range
+	if err := json.Unmarshal(data, (*plain)(r)); err != nil {
+		return err
+	}
+	t := r.Type
+	if _, err := r.parseBounds(); err != nil {
+		return err
+	}
+	if r.Type != t {
+		return ErrMalformedRange
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package gossip
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewRange(t *testing.T) {
+	tests := []struct {
+		in    string
+		typ   RangeType
+		lower *Bound
+		upper *Bound
+	}{
+		{
+			"[2010 TO 2020]",
+			RangeNumber,
+			&Bound{Text: "2010", Inclusive: true, Number: 2010},
+			&Bound{Text: "2020", Inclusive: true, Number: 2020},
+		},
+		{
+			"{-1.5 TO 2}",
+			RangeNumber,
+			&Bound{Text: "-1.5", Number: -1.5},
+			&Bound{Text: "2", Number: 2},
+		},
+		{"{10 TO *]", RangeNumber, &Bound{Text: "10", Number: 10}, nil},
+		{"[* TO 10}", RangeNumber, nil, &Bound{Text: "10", Number: 10}},
+		{"[5  TO\t5]", RangeNumber, &Bound{Text: "5", Inclusive: true, Number: 5}, &Bound{Text: "5", Inclusive: true, Number: 5}},
+		{">=10", RangeNumber, &Bound{Text: "10", Inclusive: true, Number: 10}, nil},
+		{">10", RangeNumber, &Bound{Text: "10", Number: 10}, nil},
+		{"<=10", RangeNumber, nil, &Bound{Text: "10", Inclusive: true, Number: 10}},
+		{
+			"<2024-01-01",
+			RangeDate,
+			nil,
+			&Bound{Text: "2024-01-01", Time: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			"[2024-01-01 TO 2024-01-01T12:00:00Z]",
+			RangeDate,
+			&Bound{Text: "2024-01-01", Inclusive: true, Time: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+			&Bound{Text: "2024-01-01T12:00:00Z", Inclusive: true, Time: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)},
+		},
+	}
+
+	for i, tt := range tests {
+		msg := fmt.Sprintf("Fails test case (%d) %s", i, tt.in)
+		r, _, err := newRange(tt.in)
+		if !assert.NoError(t, err, msg) {
+			continue
+		}
+		assert.Equal(t, tt.typ, r.Type, msg)
+		assert.Equal(t, tt.lower, r.Lower, msg)
+		assert.Equal(t, tt.upper, r.Upper, msg)
+		assert.True(t, r.IsValid(), msg)
+	}
+}
+
+func TestNewRangeFailures(t *testing.T) {
+	tests := []struct {
+		in     string
+		offset int
+	}{
+		{"", 0},
+		{"2010", 0},
+		{"[2010 TO 2020", 13},
+		{"[2010 2020]", 1},
+		{"[2010 to 2020]", 1},
+		{"[2010 TO 2020 TO 2030]", 1},
+		{"[* TO *]", 1},
+		{"[x TO 2020]", 1},
+		{"[2010 TO x]", 9},
+		{"[2020 TO 2010]", 9},
+		{"[2010 TO 2024-01-01]", 9},
+		{"[NaN TO 1]", 1},
+		{"[1e999 TO *]", 1},
+		{">", 1},
+		{">=*", 2},
+		{"<2024-13-01", 1},
+	}
+
+	for i, tt := range tests {
+		msg := fmt.Sprintf("Fails test case (%d) %s", i, tt.in)
+		r, offset, err := newRange(tt.in)
+		assert.Nil(t, r, msg)
+		assert.Equal(t, ErrMalformedRange, err, msg)
+		assert.Equal(t, tt.offset, offset, msg)
+	}
+}
+
+func TestRangeIsValid(t *testing.T) {
+	tests := []struct {
+		in  *Range
+		out bool
+	}{
+		{nil, false},
+		{&Range{}, false},
+		{&Range{Type: RangeNumber}, false},
+		{&Range{Type: RangeNumber, Lower: &Bound{Text: "1"}}, true},
+		{&Range{Type: RangeDate, Lower: &Bound{Text: "1"}}, false},
+		{&Range{Type: RangeDate, Upper: &Bound{Text: "2024-01-01"}}, true},
+		{&Range{Type: RangeNumber, Lower: &Bound{Text: "2"}, Upper: &Bound{Text: "1"}}, false},
+		{&Range{Type: RangeNumber, Lower: &Bound{Text: "x"}}, false},
+	}
+
+	for i, tt := range tests {
+		msg := fmt.Sprintf("Fails test case (%d)", i)
+		assert.Equal(t, tt.out, tt.in.IsValid(), msg)
+	}
+}
+
+func TestRangeEquals(t *testing.T) {
+	r0, _, _ := newRange("[1 TO 2]")
+	r1, _, _ := newRange("[1 TO 2]")
+	r2, _, _ := newRange("{1 TO 2]")
+	r3, _, _ := newRange(">=1")
+	assert.True(t, r0.Equals(r1))
+	assert.False(t, r0.Equals(r2))
+	assert.False(t, r0.Equals(r3))
+	assert.False(t, r0.Equals(nil))
+	assert.True(t, (*Range)(nil).Equals(nil))
+}
+
+func TestRangeString(t *testing.T) {
+	tests := []struct {
+		in  string
+		out string
+	}{
+		{"[2010 TO 2020]", "[2010 TO 2020]"},
+		{"{2010  TO 2020]", "{2010 TO 2020]"},
+		{"[2010 TO *]", ">=2010"},
+		{"{* TO 2020}", "<2020"},
+		{"<=2024-01-01", "<=2024-01-01"},
+	}
+
+	for i, tt := range tests {
+		msg := fmt.Sprintf("Fails test case (%d) %s", i, tt.in)
+		r, _, err := newRange(tt.in)
+		assert.NoError(t, err, msg)
+		assert.Equal(t, tt.out, r.String(), msg)
+	}
+	assert.Equal(t, "", (*Range)(nil).String())
+}
+
+func TestRangeTypeString(t *testing.T) {
+	assert.Equal(t, "number", RangeNumber.String())
+	assert.Equal(t, "date", RangeDate.String())
+	assert.Equal(t, "_error", RangeType(0).String())
+}
+
+func TestRangeUnmarshalJSON(t *testing.T) {
+	var r Range
+	err := json.Unmarshal([]byte(`{"type":1,"lower":{"text":"1.5","inclusive":true}}`), &r)
+	assert.NoError(t, err)
+	assert.Equal(t, 1.5, r.Lower.Number)
+
+	for _, data := range []string{
+		`{"type":2,"lower":{"text":"1.5"}}`,
+		`{"type":1,"lower":{"text":"x"}}`,
+		`{"type":1,"lower":{"text":"2"},"upper":{"text":"1"}}`,
+	} {
+		assert.Error(t, json.Unmarshal([]byte(data), &r), data)
+	}
+}
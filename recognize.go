@@ -0,0 +1,38 @@
+package gossip
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Patterns of the terms recognized by parsers created with
+// WithRecognizers.  Each must match the entire term.
+var (
+	urlPattern        = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9+.-]*://[^\s"\\]+$`)
+	emailPattern      = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._%+-]*@[A-Za-z0-9-]+(\.[A-Za-z0-9-]+)+$`)
+	identifierPattern = regexp.MustCompile(`^[A-Za-z0-9_]+([-+._][A-Za-z0-9_]+)+$`)
+)
+
+// recognize returns the kind of the term if it is a URL, such as
+// https://example.com/a-b?x=1, an email address, such as
+// ops+alerts@corp.io, or a version-like identifier, such as
+// foo-bar_v2.3, which must contain a digit.  Otherwise, it returns
+// KindText.
+func recognize(s string) NodeKind {
+	switch {
+	case urlPattern.MatchString(s):
+		return KindURL
+	case emailPattern.MatchString(s):
+		return KindEmail
+	case identifierPattern.MatchString(s) && strings.ContainsAny(s, "0123456789"):
+		return KindIdentifier
+	}
+	return KindText
+}
+
+// endsRecognized states if the input ends a recognized term in the
+// parser's dialect, namely a separator, subquery end, phrase delimiter
+// or boost rune.
+func (p *Parser) endsRecognized(r rune) bool {
+	return p.IsSeparator(r) || p.IsSubqueryEnd(r) || p.IsPhraseDelim(r) || p.IsBoost(r)
+}
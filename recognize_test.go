@@ -0,0 +1,37 @@
+package gossip
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecognize(t *testing.T) {
+	tests := []struct {
+		in  string
+		out NodeKind
+	}{
+		{"https://example.com/a-b?x=1", KindURL},
+		{"git+ssh://host/repo.git", KindURL},
+		{"ops+alerts@corp.io", KindEmail},
+		{"a.b@mail.corp-x.io", KindEmail},
+		{"foo-bar_v2.3", KindIdentifier},
+		{"2024-01-01", KindIdentifier},
+		{"v1.2.3-beta+build.5", KindIdentifier},
+		{"golang", KindText},
+		{"e-mail", KindText},
+		{"c++", KindText},
+		{"v1.2.", KindText},
+		{"https://", KindText},
+		{"https://a b", KindText},
+		{"-a@b.io", KindText},
+		{"a@b", KindText},
+		{"", KindText},
+	}
+
+	for i, tt := range tests {
+		msg := fmt.Sprintf("Fails test case (%d) %s", i, tt.in)
+		assert.Equal(t, tt.out, recognize(tt.in), msg)
+	}
+}
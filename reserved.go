@@ -2,96 +2,394 @@ package gossip
 
 import (
 	"strings"
+	"unicode"
 	"unicode/utf8"
 )
 
-// Reserved runes that have special meaning in search queries.
+// Reserved runes that have special meaning in the default dialect.
 const (
 	Space        rune = 0x00000020
 	Quote        rune = 0x00000022
+	Hash         rune = 0x00000023
+	LeftParen    rune = 0x00000028
+	RightParen   rune = 0x00000029
 	Plus         rune = 0x0000002b
 	Comma        rune = 0x0000002c
 	Minus        rune = 0x0000002d
+	Colon        rune = 0x0000003a
 	LeftBracket  rune = 0x0000005b
 	RightBracket rune = 0x0000005d
+	Caret        rune = 0x0000005e
+	Escape       rune = 0x0000005c // reverse solidus, \
 	Tilde        rune = 0x0000007e
-	// LeftParen    rune = 0x00000028
-	// RightParen   rune = 0x00000029
-	// At           rune = 0x00000040
-	// Escape       rune = 0x0000005c // reverse solidus, \
 )
 
+// Wildcard runes, which have a special meaning in bare terms only.
+const (
+	Asterisk     rune = 0x0000002a // matches any sequence of runes
+	QuestionMark rune = 0x0000003f // matches any single rune
+)
+
+// Runes that only have a special meaning in certain positions, and so are
+// not reserved.  Slash delimits regular expression literals, as in
+// /go(lang)?/, at the start of a clause.  At begins a minimum should match
+// suffix, as in [a b c]@2, directly after a subquery.  Ampersand and
+// Exclamation are group prefixes, as in &[a b] and ![a b], directly
+// before a subquery at the start of a clause.  EqualsSign is an
+// exact-match prefix, as in =Go, directly before a term or phrase literal
+// at the start of a clause.
+const (
+	Exclamation rune = 0x00000021
+	Ampersand   rune = 0x00000026
+	Slash       rune = 0x0000002f
+	EqualsSign  rune = 0x0000003d
+	At          rune = 0x00000040
+)
+
+// literal stands in for an escaped reserved rune when checking rune
+// sequences, since an escaped rune behaves like any non-reserved rune.
+const literal rune = utf8.MaxRune
+
 // Reserved rune aliases.
 const (
 	SubqueryStart rune = LeftBracket
 	SubqueryEnd   rune = RightBracket
 	PhraseDelim   rune = Quote
+	FieldDelim    rune = Colon
 )
 
-var reservedRuneLookup map[rune]struct{} = map[rune]struct{}{
-	Space:        struct{}{},
-	Comma:        struct{}{},
-	Quote:        struct{}{},
-	Plus:         struct{}{},
-	Minus:        struct{}{},
-	Tilde:        struct{}{},
-	LeftBracket:  struct{}{},
-	RightBracket: struct{}{},
-}
-
+// IsReserved states if the input has a special meaning in search queries.
 func IsReserved(r rune) bool {
-	_, ok := reservedRuneLookup[r]
-	return ok
+	return defaultParser.IsReserved(r)
 }
 
 // IsSeparator states if the input denotes a query object separator.
 // An object can be words, phrases, or subqueries.
-// Currently, a " " and "," are considered equivalent separators.
+// Currently, white space such as " ", tabs and line breaks, and ","
+// are considered equivalent separators.
 func IsSeparator(r rune) bool {
-	return r == Space || r == Comma
+	return defaultParser.IsSeparator(r)
 }
 
 // IsSubqueryStarts states if the input denotes the start of a nested subquery.
 func IsSubqueryStart(r rune) bool {
-	return r == SubqueryStart
+	return defaultParser.IsSubqueryStart(r)
 }
 
 // IsSubqueryEnd states if the input denotes the end of a nested subquery.
 func IsSubqueryEnd(r rune) bool {
-	return r == SubqueryEnd
+	return defaultParser.IsSubqueryEnd(r)
 }
 
 // IsPhraseDelim states if the input indicates the start of a phrase literal.
 func IsPhraseDelim(r rune) bool {
-	return r == Quote
+	return defaultParser.IsPhraseDelim(r)
+}
+
+// IsFieldDelim states if the input separates a field name from the
+// term, phrase or subquery it qualifies.
+func IsFieldDelim(r rune) bool {
+	return defaultParser.IsFieldDelim(r)
+}
+
+// IsEscape states if the input escapes the rune that follows it.
+func IsEscape(r rune) bool {
+	return r == Escape
 }
 
-// nextReserved reports the next reserved rune and its index.
-// If no reserved runes are found, the returned rune is a utf8.RuneError
-// with index -1.
+// NextReserved reports the next unescaped reserved rune and its index.
+// Escape sequences are skipped, so the reverse solidus itself is never
+// reported.  If no reserved runes are found, the returned rune is a
+// utf8.RuneError with index -1.
 func NextReserved(s string) (rune, int) {
+	return defaultParser.NextReserved(s)
+}
+
+// IsReserved states if the input has a special meaning in the parser's
+// dialect.
+func (p *Parser) IsReserved(r rune) bool {
+	_, ok := p.reserved[r]
+	return ok || unicode.IsSpace(r)
+}
+
+// IsSeparator states if the input denotes a query object separator
+// in the parser's dialect.  White space, as defined by unicode.IsSpace,
+// is always a separator.
+func (p *Parser) IsSeparator(r rune) bool {
+	_, ok := p.separators[r]
+	return ok || unicode.IsSpace(r)
+}
+
+// IsSubqueryStart states if the input denotes the start of a nested
+// subquery in the parser's dialect.
+func (p *Parser) IsSubqueryStart(r rune) bool {
+	for _, d := range p.groups {
+		if r == d.Start {
+			return true
+		}
+	}
+	return false
+}
+
+// IsSubqueryEnd states if the input denotes the end of a nested
+// subquery in the parser's dialect.
+func (p *Parser) IsSubqueryEnd(r rune) bool {
+	for _, d := range p.groups {
+		if r == d.End {
+			return true
+		}
+	}
+	return false
+}
+
+// groupEnd returns the rune that closes the subquery started by the
+// input, or utf8.RuneError if the input does not start a subquery.
+func (p *Parser) groupEnd(r rune) rune {
+	for _, d := range p.groups {
+		if r == d.Start {
+			return d.End
+		}
+	}
+	return utf8.RuneError
+}
+
+// IsPhraseDelim states if the input indicates the start or end of a
+// phrase literal in the parser's dialect.
+func (p *Parser) IsPhraseDelim(r rune) bool {
+	for _, d := range p.phrases {
+		if r == d.Start || r == d.End {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFieldDelim states if the input separates a field name from the
+// clause it qualifies in the parser's dialect.
+func (p *Parser) IsFieldDelim(r rune) bool {
+	return p.field != 0 && r == p.field
+}
+
+// IsFuzzy states if the input begins a fuzziness or slop suffix, as in
+// golang~2, when it directly follows a term or phrase literal in the
+// parser's dialect.
+func (p *Parser) IsFuzzy(r rune) bool {
+	return p.fuzzy != 0 && r == p.fuzzy
+}
+
+// IsBoost states if the input begins a boost suffix, as in golang^2, when
+// it directly follows a term, phrase literal or subquery in the parser's
+// dialect.
+func (p *Parser) IsBoost(r rune) bool {
+	return p.boost != 0 && r == p.boost
+}
+
+// IsMinShould states if the input begins a minimum should match suffix,
+// as in [a b c]@2, when it directly follows a subquery in the parser's
+// dialect.
+func (p *Parser) IsMinShould(r rune) bool {
+	return p.minShould != 0 && r == p.minShould
+}
+
+// isWildcard states if the input is a wildcard in bare terms of the
+// parser's dialect.
+func (p *Parser) isWildcard(r rune) bool {
+	return p.wildcards && (r == Asterisk || r == QuestionMark)
+}
+
+// groupPrefix returns the implicit verb that the input sets for the
+// clauses of the subquery it directly precedes in the parser's dialect,
+// or 0 if it is not a group prefix.
+func (p *Parser) groupPrefix(r rune) Verb {
+	return p.prefixes[r]
+}
+
+// isExact states if the input is the exact-match prefix in the parser's
+// dialect.
+func (p *Parser) isExact(r rune) bool {
+	return p.exact != 0 && r == p.exact
+}
+
+// IsRuneVerb states if the input represents a modal verb in the
+// parser's dialect.
+func (p *Parser) IsRuneVerb(r rune) bool {
+	_, ok := p.verbs[r]
+	return ok
+}
+
+// runeVerb returns the modal verb the input represents in the parser's
+// dialect, or VerbError if it does not represent one.
+func (p *Parser) runeVerb(r rune) Verb {
+	if v, ok := p.verbs[r]; ok {
+		return v
+	}
+	return VerbError
+}
+
+// phraseEnd returns the rune that ends a phrase literal started by the
+// input, or utf8.RuneError if the input does not start a phrase literal.
+func (p *Parser) phraseEnd(r rune) rune {
+	for _, d := range p.phrases {
+		if r == d.Start {
+			return d.End
+		}
+	}
+	return utf8.RuneError
+}
+
+// NextReserved reports the next unescaped reserved rune in the parser's
+// dialect and its index.  If no reserved runes are found, the returned
+// rune is a utf8.RuneError with index -1.
+func (p *Parser) NextReserved(s string) (rune, int) {
+	var escaped bool
 	for i, r := range s {
-		if IsReserved(r) {
+		switch {
+		case escaped:
+			escaped = false
+		case IsEscape(r):
+			escaped = true
+		case p.IsReserved(r):
 			return r, i
 		}
 	}
 	return utf8.RuneError, -1
 }
 
+// isEscaped reports whether the rune starting at byte index i of s is
+// preceded by an odd number of escape runes.
+func isEscaped(s string, i int) bool {
+	var n int
+	for i > 0 {
+		r, w := utf8.DecodeLastRuneInString(s[:i])
+		if !IsEscape(r) {
+			break
+		}
+		n++
+		i -= w
+	}
+	return n%2 == 1
+}
+
+// indexUnescaped returns the index of the first unescaped instance of r
+// in s, or -1 if r is not present.
+func indexUnescaped(s string, r rune) int {
+	var escaped bool
+	for i, ri := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case ri == r:
+			return i
+		case IsEscape(ri):
+			escaped = true
+		}
+	}
+	return -1
+}
+
+// unescape removes the escape runes from s, so that `c\+\+` becomes `c++`
+// and `\\` becomes `\`.  It reports false if s ends in a dangling escape.
+func unescape(s string) (string, bool) {
+	if strings.IndexRune(s, Escape) == -1 {
+		return s, true
+	}
+
+	var (
+		b       strings.Builder
+		escaped bool
+	)
+	for _, r := range s {
+		if IsEscape(r) && !escaped {
+			escaped = true
+			continue
+		}
+		escaped = false
+		b.WriteRune(r)
+	}
+	return b.String(), !escaped
+}
+
+// escapePhrase escapes the runes in s that cannot otherwise appear
+// inside a phrase literal, namely quotation marks and escapes.
+func escapePhrase(s string) string {
+	return escape(s, func(r rune) bool {
+		return IsPhraseDelim(r) || IsEscape(r)
+	})
+}
+
+// escape escapes the runes in s for which the input function is true.
+func escape(s string, f func(rune) bool) string {
+	var b strings.Builder
+	for _, r := range s {
+		if f(r) {
+			b.WriteRune(Escape)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
 // IsValidPair states whether the ordered pair of reserved runes is a valid combination.
 // This is useful for basic look behind checks.
 // If the prev rune is utf8.RuneError, then the current rune is assumed
 // to be the initial rune in a string.
 //
 // Valid combinations are:
-//    +-  "  [  ]  _,
-// +-  x  o  o  x  x
-//  "  x  ?  x  o  o
-//  [  o  o  o  o  o
-//  ]  x  x  x  o  o
-// _,  o  o  o  o  o
-//  r  x  x  x  o  o
+//    +-  "  [  ]  _,  \  :
+// +-  x  o  o  x  x  o  x
+//  "  x  ?  x  o  o  o  o
+//  [  o  o  o  o  o  o  x
+//  ]  x  x  x  o  o  x  x
+// _,  o  o  o  o  o  o  x
+//  r  x  x  x  o  o  o  o
+//  \  o  o  o  o  o  o  o
+//  :  x  o  o  x  x  o  x
+//
+// The _, row and column stand for any separator, including white space,
+// and the +- row and column for any verb rune, including the # of Filter.
+// Any rune following an escape is literal, and so valid.  An escape
+// cannot be the terminal rune.  A ~ directly following a term or phrase
+// literal begins a fuzziness or slop suffix, and so is valid if not
+// terminal.  Similarly, a ^ directly following a term, phrase literal or
+// subquery begins a boost suffix, and an @ directly following a subquery
+// begins a minimum should match suffix.  Suffixes are followed by
+// non-reserved runes.  A group prefix, such as &, is valid directly
+// before a subquery, and an exact-match prefix, such as =, directly
+// before a phrase literal.
+//
+// In a dialect created with WithClauseStartVerbs, a verb rune can also be
+// literal text within a term, and so a pair is valid if it is valid when
+// the verb runes in it are read as non-reserved runes.  For instance, the
+// +- row and column are then valid against r, +-, _, and :, and a verb
+// can be the terminal rune, as in c++.
 func IsPairValid(prev rune, curr rune) bool {
+	return defaultParser.IsPairValid(prev, curr)
+}
+
+// IsPairValid states whether the ordered pair of runes is a valid
+// combination in the parser's dialect.  See the IsPairValid function.
+func (p *Parser) IsPairValid(prev rune, curr rune) bool {
+	if p.pairValid(prev, curr) {
+		return true
+	}
+	if !p.clauseVerbs {
+		return false
+	}
+
+	// Read verb runes as literal text within a term.
+	lp, lc := prev, curr
+	if p.IsRuneVerb(lp) {
+		lp = literal
+	}
+	if p.IsRuneVerb(lc) {
+		lc = literal
+	}
+	return (lp != prev || lc != curr) && p.pairValid(lp, lc)
+}
+
+// pairValid states whether the ordered pair of runes is a valid
+// combination in the parser's dialect when verb runes are read as verbs.
+func (p *Parser) pairValid(prev rune, curr rune) bool {
 	if prev == curr && prev == utf8.RuneError {
 		return false
 	}
@@ -100,7 +398,7 @@ func IsPairValid(prev rune, curr rune) bool {
 	var (
 		first bool
 		last  bool
-		p     rune = prev
+		pr    rune = prev
 		c     rune = curr
 	)
 
@@ -111,32 +409,61 @@ func IsPairValid(prev rune, curr rune) bool {
 		last = true
 		c = prev
 	}
-	lit := IsPhraseDelim(prev)
+	lit := p.IsPhraseDelim(prev)
+
+	// The current rune is escaped.
+	if IsEscape(pr) && !last {
+		return true
+	}
+
+	// A boost is followed by its argument.
+	if p.IsBoost(pr) && !last {
+		return !p.IsReserved(c)
+	}
 
 	var ok bool
 	switch {
-	case IsRuneVerb(c):
+	case p.IsBoost(c):
+		ok = !last && !first && (lit || p.IsSubqueryEnd(pr) || !p.IsReserved(pr))
+
+	case p.IsFuzzy(c) && !first && (lit || !p.IsReserved(pr)):
+		// A suffix must be followed by its argument.
+		ok = !last
+
+	case IsEscape(c):
+		// Fail if last or the previous is a subquery.
+		ok = !last && (first || !p.IsSubqueryEnd(pr))
+
+	case p.IsRuneVerb(c):
 		// Fail if last or second condition not met.
-		ok = !last && (first || lit || IsSubqueryStart(p) || IsSeparator(p))
+		ok = !last && (first || lit || p.IsSubqueryStart(pr) || p.IsSeparator(pr))
 
-	case IsPhraseDelim(c):
-		ok = last || first || (IsReserved(p) && !IsSubqueryEnd(p))
+	case p.IsPhraseDelim(c):
+		ok = last || first || p.isExact(pr) || (p.IsReserved(pr) && !p.IsSubqueryEnd(pr))
 
-	case IsSubqueryStart(c):
+	case p.IsSubqueryStart(c):
 		// Fail if last or second condition not met.
-		ok = !last && (first || lit || IsReserved(p) && !IsPhraseDelim(p) && !IsSubqueryEnd(p))
+		ok = !last && (first || lit || p.groupPrefix(pr) != 0 ||
+			p.IsReserved(pr) && !p.IsPhraseDelim(pr) && !p.IsSubqueryEnd(pr))
+
+	case p.IsSubqueryEnd(c):
+		// Fail if first or previous is a verb or field delimiter.
+		ok = last || (!first && !p.IsRuneVerb(pr) && !p.IsFieldDelim(pr))
 
-	case IsSubqueryEnd(c):
-		// Fail if first or previous is a verb.
-		ok = last || (!first && !IsRuneVerb(p))
+	case p.IsSeparator(c):
+		// Previous cannot be a verb or field delimiter.
+		ok = !p.IsRuneVerb(pr) && !p.IsFieldDelim(pr)
 
-	case IsSeparator(c):
-		// Previous cannot be a verb.
-		ok = !IsRuneVerb(p)
+	case p.IsFieldDelim(c):
+		// A field delimiter must follow a field name, and be followed by
+		// the clause the field qualifies.  It is literal at the start of a
+		// phrase literal.
+		ok = lit || !last && !first && !p.IsReserved(pr)
 
-	case !IsReserved(c):
-		// Previous cannot be a subquery.
-		ok = first || !IsSubqueryEnd(p)
+	case !p.IsReserved(c):
+		// Previous cannot be a subquery, unless a minimum should match
+		// suffix begins.
+		ok = first || !p.IsSubqueryEnd(pr) || p.IsMinShould(c)
 	}
 	return ok
 }
@@ -151,28 +478,44 @@ func IsPairValid(prev rune, curr rune) bool {
 //
 // The Validity of a triple depends primarily on the first two elements.
 func IsTripleValid(prev rune, curr rune, next rune) bool {
+	return defaultParser.IsTripleValid(prev, curr, next)
+}
+
+// IsTripleValid states whether the ordered triple of runes represents
+// a valid sequence in the parser's dialect.  See the IsTripleValid
+// function.
+func (p *Parser) IsTripleValid(prev rune, curr rune, next rune) bool {
 	if prev == next && prev == utf8.RuneError {
-		if curr == PhraseDelim {
+		if p.IsPhraseDelim(curr) {
 			return false
 		}
 
 	}
-	return IsPairValid(prev, curr) && IsPairValid(curr, next)
+	return p.IsPairValid(prev, curr) && p.IsPairValid(curr, next)
 }
 
 // checkReserved determiens if a reserved rune is in a valid sequence.
 // Matrix of acceptable (prev, curr) rune pairs. Current control rune on top.
 // Previous rune on left.  Here _ is a space and r any non-reserved rune.
-//    +-  "  [  ]  _,
-// +-  x  o  o  x  x
-//  "  x  ?  x  o  o
-//  [  o  o  o  ?  o
-//  ]  x  x  x  o  ?
-// _,  o  o  o  o  o
-//  r  x  x  x  o  o
+//    +-  "  [  ]  _,  \  :
+// +-  x  o  o  x  x  o  x
+//  "  x  ?  x  o  o  o  o
+//  [  o  o  o  ?  o  o  x
+//  ]  x  x  x  o  ?  x  x
+// _,  o  o  o  o  o  o  x
+//  r  x  x  x  o  o  o  o
+//  \  o  o  o  o  o  o  o
+//  :  x  o  o  x  x  o  x
 //
 // Most reserved runes can be the initial but not terminal rune in the string.
+// An escaped previous rune is treated as a non-reserved rune.
 func checkReserved(s string, r rune, loc int, width int) bool {
+	return defaultParser.checkReserved(s, r, loc, width)
+}
+
+// checkReserved determines if a reserved rune is in a valid sequence
+// in the parser's dialect.  See the checkReserved function.
+func (p *Parser) checkReserved(s string, r rune, loc int, width int) bool {
 	var (
 		prev      rune = utf8.RuneError
 		next      rune = utf8.RuneError
@@ -189,6 +532,9 @@ func checkReserved(s string, r rune, loc int, width int) bool {
 	if prev == utf8.RuneError && w == 1 {
 		return false
 	}
+	if w > 0 && isEscaped(s, loc-w) {
+		prev = literal
+	}
 	nextIndex = loc + width
 
 	// Decode next rune, or remember that it does not exist.
@@ -200,9 +546,10 @@ func checkReserved(s string, r rune, loc int, width int) bool {
 		next = tmp
 	}
 
-	return IsTripleValid(prev, r, next)
+	return p.IsTripleValid(prev, r, next)
 }
 
+// indexNonPhraseRune returns the index of the first unescaped instance of r
 // that is not contained in a phrase literal.  For instance,
 // if s = `[word1 "phrase with brackets[]" word2]` and r = `]`, then
 // the index returned is len(s)-1.
@@ -216,13 +563,20 @@ func indexNonPhraseRune(s string, r rune) int {
 
 		i += w
 
+		// Skip the escaped rune.
+		if IsEscape(ri) && i < len(s) {
+			_, w = utf8.DecodeRuneInString(s[i:])
+			i += w
+			continue
+		}
+
 		// If we see a quotation, find the matching mark and resume the search.
 		if ri == Quote && i < len(s) {
-			j := strings.IndexRune(s[i:], Quote)
+			j := indexUnescaped(s[i:], Quote)
 			if j == -1 {
 				return -1
 			}
-			i += j
+			i += j + utf8.RuneLen(Quote)
 		}
 
 	}
@@ -19,10 +19,19 @@ func TestNextReservedRune(t *testing.T) {
 		{"日本語", utf8.RuneError, -1},
 		{"[", SubqueryStart, 0},
 		{"0]2", SubqueryEnd, 1},
+		{"f(x)", LeftParen, 1},
+		{`f\(x\)`, utf8.RuneError, -1},
 		{`0"`, PhraseDelim, 1},
 		{`0+"567+"`, rune(Must), 1},
 		{`0-"567+"`, rune(Not), 1},
 		{`0123 "`, Space, 4},
+		{`c\+\+ x`, Space, 5},
+		{"golang\r\nrust", '\r', 6},
+		{"a\tb", '\t', 1},
+		{"日本\u00a0語", '\u00a0', 6},
+		{`\\+`, rune(Must), 2},
+		{`\[\]\"`, utf8.RuneError, -1},
+		{`0\`, utf8.RuneError, -1},
 	}
 
 	for i, tt := range tests {
@@ -70,6 +79,16 @@ func TestCheckReservedSingleton(t *testing.T) {
 
 }
 
+func TestIsSeparator(t *testing.T) {
+	for _, r := range []rune{Space, Comma, '\t', '\n', '\r', '\v', '\f', '\u00a0', '\u2003', '\u3000'} {
+		assert.True(t, IsSeparator(r), fmt.Sprintf("%q", r))
+		assert.True(t, IsReserved(r), fmt.Sprintf("%q", r))
+	}
+	for _, r := range []rune{'a', '_', Plus, '\u200b'} {
+		assert.False(t, IsSeparator(r), fmt.Sprintf("%q", r))
+	}
+}
+
 func TestIsValidPair(t *testing.T) {
 	a, _ := utf8.DecodeRuneInString("a")
 	e := utf8.RuneError
@@ -86,6 +105,7 @@ func TestIsValidPair(t *testing.T) {
 		{SubqueryStart, rune(Must), true},
 		{SubqueryEnd, rune(Must), false},
 		{Space, rune(Must), true},
+		{'\n', rune(Must), true},
 		{e, rune(Must), true},
 		{a, rune(Must), false},
 		// current = minus
@@ -106,6 +126,7 @@ func TestIsValidPair(t *testing.T) {
 		{Space, PhraseDelim, true},
 		{e, PhraseDelim, true},
 		{a, PhraseDelim, false},
+		{Escape, PhraseDelim, true},
 		// current = subquery start
 		{rune(Must), SubqueryStart, true},
 		{rune(Not), SubqueryStart, true},
@@ -115,6 +136,9 @@ func TestIsValidPair(t *testing.T) {
 		{Space, SubqueryStart, true},
 		{e, SubqueryStart, true},
 		{a, SubqueryStart, false},
+		{EqualsSign, PhraseDelim, true},
+		{Ampersand, SubqueryStart, true},
+		{Exclamation, SubqueryStart, true},
 		// current =Subuery end
 		{rune(Must), SubqueryEnd, false},
 		{rune(Not), SubqueryEnd, false},
@@ -124,6 +148,7 @@ func TestIsValidPair(t *testing.T) {
 		{Space, SubqueryEnd, true},
 		{e, SubqueryEnd, false},
 		{a, SubqueryEnd, true},
+		{Escape, SubqueryEnd, true},
 		// current = space
 		{rune(Must), Space, false},
 		{rune(Not), Space, false},
@@ -139,9 +164,19 @@ func TestIsValidPair(t *testing.T) {
 		{PhraseDelim, a, true},
 		{SubqueryStart, a, true},
 		{SubqueryEnd, a, false},
+		{SubqueryEnd, At, true},
 		{Space, a, true},
 		{e, a, true},
 		{a, a, true},
+		// current = escape
+		{rune(Must), Escape, true},
+		{PhraseDelim, Escape, true},
+		{SubqueryStart, Escape, true},
+		{SubqueryEnd, Escape, false},
+		{Space, Escape, true},
+		{e, Escape, true},
+		{a, Escape, true},
+		{Escape, Escape, true},
 		// Last
 		{rune(Must), e, false},
 		{rune(Not), e, false},
@@ -151,6 +186,49 @@ func TestIsValidPair(t *testing.T) {
 		{Space, e, true},
 		{e, e, false},
 		{a, e, true},
+		{Escape, e, false},
+		// current = fuzzy suffix
+		{a, Tilde, true},
+		{Escape, Tilde, true},
+		{PhraseDelim, Tilde, true},
+		{SubqueryEnd, Tilde, false},
+		{FieldDelim, Tilde, false},
+		{a, e, true},
+		{Tilde, e, false},
+		// current = boost
+		{a, Caret, true},
+		{PhraseDelim, Caret, true},
+		{SubqueryEnd, Caret, true},
+		{Escape, Caret, true},
+		{Space, Caret, false},
+		{SubqueryStart, Caret, false},
+		{rune(Must), Caret, false},
+		{e, Caret, false},
+		{Caret, e, false},
+		// previous = boost
+		{Caret, a, true},
+		{Caret, Caret, false},
+		{Caret, Space, false},
+		{Caret, SubqueryEnd, false},
+		{Caret, PhraseDelim, false},
+		// current = field delimiter
+		{a, FieldDelim, true},
+		{Escape, FieldDelim, true},
+		{rune(Must), FieldDelim, false},
+		{PhraseDelim, FieldDelim, true},
+		{SubqueryEnd, FieldDelim, false},
+		{Space, FieldDelim, false},
+		{FieldDelim, FieldDelim, false},
+		{e, FieldDelim, false},
+		{FieldDelim, e, false},
+		// previous = field delimiter
+		{FieldDelim, a, true},
+		{FieldDelim, PhraseDelim, true},
+		{FieldDelim, SubqueryStart, true},
+		{FieldDelim, Escape, true},
+		{FieldDelim, rune(Must), false},
+		{FieldDelim, SubqueryEnd, false},
+		{FieldDelim, Space, false},
 	}
 
 	for i, tt := range tests {
@@ -161,6 +239,38 @@ func TestIsValidPair(t *testing.T) {
 	}
 }
 
+func TestIsValidPairClauseStartVerbs(t *testing.T) {
+	p := NewParser(WithClauseStartVerbs(true))
+	a, _ := utf8.DecodeRuneInString("a")
+	e := utf8.RuneError
+
+	tests := []struct {
+		prev rune
+		curr rune
+		out  bool
+	}{
+		// Verbs are also literal text within a term.
+		{a, rune(Not), true},
+		{rune(Must), rune(Must), true},
+		{FieldDelim, rune(Not), true},
+		{rune(Must), e, true},
+		{rune(Not), Space, true},
+		{rune(Not), SubqueryEnd, true},
+		// Verbs at the start of a clause are unchanged.
+		{Space, rune(Must), true},
+		{e, rune(Must), true},
+		{SubqueryEnd, rune(Must), false},
+		// Other pairs are unchanged.
+		{SubqueryEnd, a, false},
+		{Space, FieldDelim, false},
+	}
+
+	for i, tt := range tests {
+		msg := fmt.Sprintf("Fails test case (%d) %#v", i, tt)
+		assert.Equal(t, tt.out, p.IsPairValid(tt.prev, tt.curr), msg)
+	}
+}
+
 // We do not tests all possible valid triples here, only ones that we
 func TestValidTriple(t *testing.T) {
 	a, _ := utf8.DecodeRuneInString("a")
@@ -216,8 +326,8 @@ func TestIndexNonPhraseRune(t *testing.T) {
 		{"anything", r0, -1},
 		{"", PhraseDelim, -1},
 		{"[", SubqueryEnd, -1},
-		// {`"\"`, Escape, -1},
-		// {`012\"`, Escape, 3}, // 5
+		{`"\"`, Escape, -1},
+		{`012\"`, Escape, 3}, // 5
 		{`0123"567+"`, rune(Must), -1},
 		{`0123"`, PhraseDelim, 4},
 		{`0123"567+"`, rune(Must), -1},
@@ -225,6 +335,9 @@ func TestIndexNonPhraseRune(t *testing.T) {
 		{"日本語", r1, 6}, // 10
 		{`日本語"+`, rune(Must), -1},
 		{`+`, rune(Must), 0},
+		{`\+ +`, rune(Must), 3},
+		{`"a\"+" +`, rune(Must), 7},
+		{`"a" "b" ]`, SubqueryEnd, 8},
 	}
 
 	for i, tt := range tests {
@@ -233,3 +346,52 @@ func TestIndexNonPhraseRune(t *testing.T) {
 	}
 
 }
+
+func TestCheckReservedEscapedPrev(t *testing.T) {
+	// The escaped + is literal, so the quotation follows a term.
+	assert.False(t, checkReserved(`c\+"x"`, PhraseDelim, 3, 1))
+	// The escape is itself escaped, so the + is a verb.
+	assert.True(t, checkReserved(`\\ +x`, rune(Must), 3, 1))
+}
+
+func TestUnescape(t *testing.T) {
+	tests := []struct {
+		in  string
+		out string
+		ok  bool
+	}{
+		{"", "", true},
+		{"plain", "plain", true},
+		{`c\+\+`, "c++", true},
+		{`say \"hi\"`, `say "hi"`, true},
+		{`\\`, `\`, true},
+		{`\x`, "x", true},
+		{`日本\語`, "日本語", true},
+		{`dangling\`, "dangling", false},
+		{`\\\`, `\`, false},
+	}
+
+	for i, tt := range tests {
+		msg := fmt.Sprintf("Fails test case (%d) %#v", i, tt)
+		out, ok := unescape(tt.in)
+		assert.Equal(t, tt.out, out, msg)
+		assert.Equal(t, tt.ok, ok, msg)
+	}
+}
+
+func TestEscapePhrase(t *testing.T) {
+	tests := []struct {
+		in  string
+		out string
+	}{
+		{"", ""},
+		{"c++", "c++"},
+		{`say "hi"`, `say \"hi\"`},
+		{`a\b`, `a\\b`},
+	}
+
+	for i, tt := range tests {
+		msg := fmt.Sprintf("Fails test case (%d) %#v", i, tt)
+		assert.Equal(t, tt.out, escapePhrase(tt.in), msg)
+	}
+}
@@ -1,9 +1,5 @@
 package gossip
 
-import (
-	"errors"
-)
-
 type Verb rune
 
 // Modal verbs.
@@ -12,6 +8,7 @@ const (
 	Should    Verb = Verb(Tilde)
 	Not       Verb = Verb(Minus)
 	Must      Verb = Verb(Plus)
+	Filter    Verb = Verb(Hash) // Must match, but does not contribute to relevance.
 )
 
 // Modal verbs as their literal string representation.
@@ -20,6 +17,7 @@ const (
 	ShouldString    string = "~"
 	NotString       string = "-"
 	MustString      string = "+"
+	FilterString    string = "#"
 )
 
 // Human readable modal verbs.
@@ -28,12 +26,14 @@ const (
 	ShouldStringPretty    string = "should"
 	NotStringPretty       string = "not"
 	MustStringPretty      string = "must"
+	FilterStringPretty    string = "filter"
 )
 
 var verbStringsForHumans = map[rune]string{
 	rune(Must):      MustStringPretty,
 	rune(Not):       NotStringPretty,
 	rune(Should):    ShouldStringPretty,
+	rune(Filter):    FilterStringPretty,
 	rune(VerbError): VerbErrorStringPretty,
 }
 
@@ -41,6 +41,7 @@ var verbStrings = map[rune]string{
 	rune(Must):      MustString,
 	rune(Not):       NotString,
 	rune(Should):    ShouldString,
+	rune(Filter):    FilterString,
 	rune(VerbError): VerbErrorString,
 }
 
@@ -51,6 +52,8 @@ var verbStringLookup = map[string]Verb{
 	NotStringPretty:    Not,
 	MustString:         Must,
 	MustStringPretty:   Must,
+	FilterString:       Filter,
+	FilterStringPretty: Filter,
 }
 
 func (v Verb) String() string {
@@ -69,7 +72,7 @@ func (v Verb) Pretty() string {
 
 // IsValid reports whether the instance is a valid modal verb.
 func (v Verb) IsValid() bool {
-	return v == Should || v == Must || v == Not
+	return v == Should || v == Must || v == Not || v == Filter
 }
 
 // IsMust reports whether the instance is the modal verb "must".
@@ -87,10 +90,14 @@ func (v Verb) IsShould() bool {
 	return v == Should
 }
 
+// IsFilter reports whether the instance is the modal verb "filter".
+func (v Verb) IsFilter() bool {
+	return v == Filter
+}
+
 // IsRuneVerb states if the input represents a modal verb such as "must".
 func IsRuneVerb(r rune) bool {
-	v := Verb(r)
-	return v == Should || v == Must || v == Not
+	return Verb(r).IsValid()
 }
 
 // IsRuneMust states if the input represents the modal verb "must".
@@ -108,6 +115,11 @@ func IsRuneShould(r rune) bool {
 	return Verb(r) == Should
 }
 
+// IsRuneFilter states if the input represents the modal verb "filter".
+func IsRuneFilter(r rune) bool {
+	return Verb(r) == Filter
+}
+
 // ParseVerbString converts a string representation of a verb into the
 // appropriate Verb instance. If the input string is not a valid Verb,
 // the VerbError Verb instance is returned, along with an error.
@@ -115,5 +127,5 @@ func ParseVerbString(verb string) (Verb, error) {
 	if v, ok := verbStringLookup[verb]; ok {
 		return v, nil
 	}
-	return VerbError, errors.New(ErrorVerbString)
+	return VerbError, ErrVerbString
 }
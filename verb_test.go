@@ -23,6 +23,13 @@ func TestVerbIsX(t *testing.T) {
 	assert.True(t, Not.IsMustNot())
 	assert.False(t, Not.IsShould())
 
+	assert.True(t, Filter.IsValid())
+	assert.False(t, Filter.IsMust())
+	assert.False(t, Filter.IsMustNot())
+	assert.False(t, Filter.IsShould())
+	assert.True(t, Filter.IsFilter())
+	assert.False(t, Must.IsFilter())
+
 	assert.False(t, Verb(-93).IsValid())
 	assert.False(t, Verb(-93).IsMust())
 	assert.False(t, Verb(-93).IsMustNot())
@@ -40,6 +47,7 @@ func TestIsVerb(t *testing.T) {
 		{rune(Must), true},
 		{rune(Should), true},
 		{rune(Not), true},
+		{rune(Filter), true},
 	}
 
 	for i, tt := range tests {
@@ -59,6 +67,7 @@ func TestIsMust(t *testing.T) {
 		{rune(Must), true},
 		{rune(Should), false},
 		{rune(Not), false},
+		{rune(Filter), false},
 	}
 
 	for i, tt := range tests {
@@ -78,6 +87,7 @@ func TestIsMustNot(t *testing.T) {
 		{rune(Must), false},
 		{rune(Should), false},
 		{rune(Not), true},
+		{rune(Filter), false},
 	}
 
 	for i, tt := range tests {
@@ -97,6 +107,7 @@ func TestIsShould(t *testing.T) {
 		{rune(Must), false},
 		{rune(Should), true},
 		{rune(Not), false},
+		{rune(Filter), false},
 	}
 
 	for i, tt := range tests {
@@ -105,6 +116,27 @@ func TestIsShould(t *testing.T) {
 	}
 }
 
+func TestIsFilter(t *testing.T) {
+	tests := []struct {
+		in  rune
+		out bool
+	}{
+		{-92, false},
+		{0, false},
+		{rune(VerbError), false},
+		{rune(Must), false},
+		{rune(Should), false},
+		{rune(Not), false},
+		{rune(Filter), true},
+		{Hash, true},
+	}
+
+	for i, tt := range tests {
+		msg := fmt.Sprintf("Fails test case (%d) %q", i, tt.in)
+		assert.Equal(t, tt.out, IsRuneFilter(tt.in), msg)
+	}
+}
+
 func TestVerbString(t *testing.T) {
 	tests := []struct {
 		in  Verb
@@ -113,6 +145,7 @@ func TestVerbString(t *testing.T) {
 		{Must, MustString},
 		{Should, ShouldString},
 		{Not, NotString},
+		{Filter, FilterString},
 		{VerbError, VerbErrorString},
 		{999, VerbErrorString},
 	}
@@ -132,6 +165,7 @@ func TestVerbPretty(t *testing.T) {
 		{Must, MustStringPretty},
 		{Should, ShouldStringPretty},
 		{Not, NotStringPretty},
+		{Filter, FilterStringPretty},
 		{VerbError, VerbErrorStringPretty},
 		{999, VerbErrorStringPretty},
 	}
@@ -153,7 +187,7 @@ func TestParseVerbString(t *testing.T) {
 	for _, tt := range fails {
 		v, err := ParseVerbString(tt)
 		assert.Equal(t, VerbError, v)
-		assert.Error(t, err)
+		assert.Equal(t, ErrVerbString, err)
 	}
 
 	passes := []struct {
@@ -166,6 +200,8 @@ func TestParseVerbString(t *testing.T) {
 		{ShouldStringPretty, Should},
 		{NotString, Not},
 		{NotStringPretty, Not},
+		{FilterString, Filter},
+		{FilterStringPretty, Filter},
 	}
 
 	for _, tt := range passes {